@@ -1,37 +1,140 @@
 package cmd
 
 import (
+	"bytes"
 	"encoding/json"
+	"fmt"
+	"io"
+	"net"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/cashshuffle/cashshuffle/server"
 
 	"github.com/mitchellh/go-homedir"
+	"github.com/spf13/pflag"
 	"github.com/zquestz/go-ucl"
 )
 
 // Config stores all the application configuration.
 type Config struct {
-	DisplayVersion   bool   `json:"-"`
-	Port             int    `json:"port,string"`
-	StatsPort        int    `json:"stats_port,string"`
-	WebSocketPort    int    `json:"websocket_port,string"`
-	Cert             string `json:"cert"`
-	Key              string `json:"key"`
-	PoolSize         int    `json:"pool_size,string"`
-	Debug            bool   `json:"debug,string"`
-	AutoCert         string `json:"auto_cert"`
-	BindIP           string `json:"bind_ip"`
-	Tor              bool   `json:"tor,string"`
-	TorBindIP        string `json:"tor_bind_ip"`
-	TorPort          int    `json:"tor_port,string"`
-	TorStatsPort     int    `json:"tor_stats_port,string"`
-	TorWebSocketPort int    `json:"tor_websocket_port,string"`
+	DisplayVersion   bool     `json:"-"`
+	Port             int      `json:"port,string"`
+	StatsPort        int      `json:"stats_port,string"`
+	WebSocketPort    int      `json:"websocket_port,string"`
+	HTTPPort         int      `json:"http_port,string"`
+	WebSocketPath    string   `json:"websocket_path"`
+	Cert             string   `json:"cert"`
+	Key              string   `json:"key"`
+	PoolSize         int      `json:"pool_size,string"`
+	Debug            bool     `json:"debug,string"`
+	AutoCert         string   `json:"auto_cert"`
+	BindIP           string   `json:"bind_ip"`
+	Tor              bool     `json:"tor,string"`
+	TorBindIP        string   `json:"tor_bind_ip"`
+	TorPort          int      `json:"tor_port,string"`
+	TorStatsPort     int      `json:"tor_stats_port,string"`
+	TorWebSocketPort int      `json:"tor_websocket_port,string"`
+	TorHTTPPort      int      `json:"tor_http_port,string"`
+	IPv4PrefixLength int      `json:"ipv4_prefix_length,string"`
+	IPv6PrefixLength int      `json:"ipv6_prefix_length,string"`
+	ProxyProtocol    []string `json:"proxy_protocol"`
+	TorProxyProtocol []string `json:"tor_proxy_protocol"`
+	TorControl       string   `json:"tor_control"`
+	TorControlPass   string   `json:"tor_control_password"`
+	TrustedProxies   []string `json:"trusted_proxies"`
+
+	MaxConnections          int `json:"max_connections,string"`
+	MaxConnectionsPerIP     int `json:"max_connections_per_ip,string"`
+	MaxConnectionsPerPrefix int `json:"max_connections_per_prefix,string"`
+	MaxPoolsPerIP           int `json:"max_pools_per_ip,string"`
+
+	PoolSeparation string `json:"pool_separation"`
+
+	ResumeGracePeriod string `json:"resume_grace_period"`
+
+	Versions []string `json:"versions"`
+
+	Drain         bool `json:"drain,string"`
+	ReadyCertDays int  `json:"ready_cert_days,string"`
+	RoundHistory  bool `json:"round_history,string"`
+
+	RateLimit             string `json:"rate_limit"`
+	WebSocketRateLimit    string `json:"websocket_rate_limit"`
+	StatsRateLimit        string `json:"stats_rate_limit"`
+	TorRateLimit          string `json:"tor_rate_limit"`
+	TorWebSocketRateLimit string `json:"tor_websocket_rate_limit"`
+	TorStatsRateLimit     string `json:"tor_stats_rate_limit"`
+
+	RedisURL    string `json:"redis_url"`
+	RedisPrefix string `json:"redis_prefix"`
+
+	LogFormat         string   `json:"log_format"`
+	LogLevel          string   `json:"log_level"`
+	LogDisableBuckets []string `json:"log_disable_buckets"`
+	LogPrivacy        string   `json:"log_privacy"`
+	LogNoLinkage      bool     `json:"log_no_linkage,string"`
+
+	WebSocketPingInterval   string   `json:"websocket_ping_interval"`
+	WebSocketPongTimeout    string   `json:"websocket_pong_timeout"`
+	WebSocketCompression    bool     `json:"websocket_compression,string"`
+	WebSocketAllowedOrigins []string `json:"websocket_allowed_origins"`
+
+	SigningKey string `json:"signing_key"`
+	DataDir    string `json:"data_dir"`
+
+	Federation            bool     `json:"federation,string"`
+	FederationHost        string   `json:"federation_host"`
+	FederationPeers       []string `json:"federation_peers"`
+	FederationInterval    string   `json:"federation_interval"`
+	FederationTrustedKeys []string `json:"federation_trusted_keys"`
+}
+
+// envPrefix prefixes the environment variables that override the config
+// file. CASHSHUFFLE_POOL_SIZE sets pool_size, for example.
+const envPrefix = "CASHSHUFFLE_"
+
+// envConfigFile is the environment variable that sets the config file path.
+const envConfigFile = envPrefix + "CONFIG"
+
+// uclKeyOrder is the key the UCL parser adds to record the order of keys.
+const uclKeyOrder = "--ucl-keyorder--"
+
+// buildConfig builds the configuration from, in order of precedence, the
+// flags set on the command line, CASHSHUFFLE_* environment variables, the
+// config file and the defaults.
+func buildConfig(flags *pflag.FlagSet) (Config, error) {
+	var c Config
+	setDefaults(&c)
+
+	path := os.Getenv(envConfigFile)
+	if f := flags.Lookup("config"); f != nil && f.Changed {
+		path = f.Value.String()
+	}
+
+	if err := c.Load(path); err != nil {
+		return c, err
+	}
+
+	if err := c.LoadEnv(os.Environ()); err != nil {
+		return c, err
+	}
+
+	if err := c.applyFlags(flags); err != nil {
+		return c, err
+	}
+
+	return c, c.validate()
 }
 
-// Load reads the configuration from ~/.cashshuffle/config and loads it into the Config struct.
+// Load reads the configuration from path and loads it into the Config struct.
+// If path is empty ~/.cashshuffle/config is read if it exists.
 // The config is in UCL format.
-func (c *Config) Load() error {
-	conf, err := c.loadConfig()
+func (c *Config) Load(path string) error {
+	conf, err := c.loadConfig(path)
 	if err != nil {
 		return err
 	}
@@ -47,6 +150,82 @@ func (c *Config) Load() error {
 	return nil
 }
 
+// LoadEnv loads CASHSHUFFLE_* variables from environ into the Config
+// struct. List values are comma separated.
+func (c *Config) LoadEnv(environ []string) error {
+	keys := configKeys()
+	values := make(map[string]interface{})
+
+	for _, e := range environ {
+		if !strings.HasPrefix(e, envPrefix) {
+			continue
+		}
+
+		parts := strings.SplitN(e, "=", 2)
+		if len(parts) != 2 || parts[0] == envConfigFile {
+			continue
+		}
+
+		key := strings.ToLower(strings.TrimPrefix(parts[0], envPrefix))
+		kind, ok := keys[key]
+		if !ok {
+			return fmt.Errorf("unknown environment variable %s", parts[0])
+		}
+
+		if kind == reflect.Slice {
+			values[key] = splitList(parts[1])
+		} else {
+			values[key] = parts[1]
+		}
+	}
+
+	conf, err := json.Marshal(values)
+	if err != nil {
+		return err
+	}
+
+	return c.applyConf(conf)
+}
+
+// applyFlags loads the flags set on the command line into the Config
+// struct. Flag names match the config keys with dashes instead of
+// underscores.
+func (c *Config) applyFlags(flags *pflag.FlagSet) error {
+	keys := configKeys()
+	values := make(map[string]interface{})
+
+	flags.Visit(func(f *pflag.Flag) {
+		key := strings.Replace(f.Name, "-", "_", -1)
+		if _, ok := keys[key]; !ok {
+			return
+		}
+
+		if s, ok := f.Value.(pflag.SliceValue); ok {
+			values[key] = s.GetSlice()
+		} else {
+			values[key] = f.Value.String()
+		}
+	})
+
+	conf, err := json.Marshal(values)
+	if err != nil {
+		return err
+	}
+
+	return c.applyConf(conf)
+}
+
+// dataDir returns the directory persisted state is kept in: the signing
+// key, the onion key and the Let's Encrypt certificates. It is the config
+// directory unless data_dir is set.
+func (c *Config) dataDir() (string, error) {
+	if c.DataDir != "" {
+		return c.DataDir, nil
+	}
+
+	return c.configDir()
+}
+
 func (c *Config) configDir() (string, error) {
 	h, err := homedir.Dir()
 	if err != nil {
@@ -56,15 +235,20 @@ func (c *Config) configDir() (string, error) {
 	return filepath.Join(h, ".cashshuffle"), nil
 }
 
-func (c *Config) loadConfig() ([]byte, error) {
-	configDir, err := c.configDir()
-	if err != nil {
-		return nil, err
+func (c *Config) loadConfig(path string) ([]byte, error) {
+	explicit := path != ""
+	if !explicit {
+		configDir, err := c.configDir()
+		if err != nil {
+			return nil, err
+		}
+
+		path = filepath.Join(configDir, "config")
 	}
 
-	f, err := os.Open(filepath.Join(configDir, "config"))
+	f, err := os.Open(path)
 	if err != nil {
-		if os.IsNotExist(err) {
+		if os.IsNotExist(err) && !explicit {
 			return nil, nil
 		}
 
@@ -72,11 +256,13 @@ func (c *Config) loadConfig() ([]byte, error) {
 	}
 	defer f.Close()
 
+	// The parser drops a last line without a trailing newline.
 	ucl.Ucldebug = false
-	data, err := ucl.NewParser(f).Ucl()
+	data, err := ucl.NewParser(io.MultiReader(f, strings.NewReader("\n"))).Ucl()
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("%s: %s", path, err)
 	}
+	delete(data, uclKeyOrder)
 
 	conf, err := json.Marshal(data)
 	if err != nil {
@@ -86,6 +272,110 @@ func (c *Config) loadConfig() ([]byte, error) {
 	return conf, nil
 }
 
+// applyConf loads JSON into the Config struct. Unknown keys and values
+// of the wrong type are errors.
 func (c *Config) applyConf(conf []byte) error {
-	return json.Unmarshal(conf, c)
+	d := json.NewDecoder(bytes.NewReader(conf))
+	d.DisallowUnknownFields()
+
+	if err := d.Decode(c); err != nil {
+		return fmt.Errorf("invalid configuration: %s", err)
+	}
+
+	return nil
+}
+
+// validate checks the values that can not be checked by their type.
+func (c *Config) validate() error {
+	ports := map[string]int{
+		"port":               c.Port,
+		"websocket_port":     c.WebSocketPort,
+		"stats_port":         c.StatsPort,
+		"tor_port":           c.TorPort,
+		"tor_websocket_port": c.TorWebSocketPort,
+		"http_port":          c.HTTPPort,
+		"tor_http_port":      c.TorHTTPPort,
+		"tor_stats_port":     c.TorStatsPort,
+	}
+	for key, port := range ports {
+		if port < 0 || port > 65535 {
+			return fmt.Errorf("invalid %s: %d", key, port)
+		}
+	}
+
+	if c.PoolSize < 1 {
+		return fmt.Errorf("invalid pool_size: %d", c.PoolSize)
+	}
+
+	if c.ReadyCertDays < 0 {
+		return fmt.Errorf("invalid ready_cert_days: %d", c.ReadyCertDays)
+	}
+
+	if err := server.ValidateWebsocketPath(c.WebSocketPath); err != nil {
+		return err
+	}
+
+	for _, ip := range []string{c.BindIP, c.TorBindIP} {
+		if ip != "" && net.ParseIP(ip) == nil {
+			return fmt.Errorf("invalid bind ip: %s", ip)
+		}
+	}
+
+	durations := map[string]string{
+		"resume_grace_period": c.ResumeGracePeriod,
+		"federation_interval": c.FederationInterval,
+	}
+	for key, formatted := range durations {
+		d, err := time.ParseDuration(formatted)
+		if err != nil {
+			return fmt.Errorf("invalid %s: %s", key, err)
+		}
+
+		if d < 0 {
+			return fmt.Errorf("invalid %s: %s", key, formatted)
+		}
+	}
+
+	if _, err := getRates(c); err != nil {
+		return err
+	}
+
+	if _, err := getWebsocketOptions(c); err != nil {
+		return err
+	}
+
+	if _, err := getVersions(c); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// configKeys returns the kind of every config key.
+func configKeys() map[string]reflect.Kind {
+	keys := make(map[string]reflect.Kind)
+
+	t := reflect.TypeOf(Config{})
+	for i := 0; i < t.NumField(); i++ {
+		name := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+
+		keys[name] = t.Field(i).Type.Kind()
+	}
+
+	return keys
+}
+
+// splitList splits a comma separated list, dropping empty items.
+func splitList(s string) []string {
+	list := make([]string, 0)
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+
+	return list
 }
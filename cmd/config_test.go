@@ -0,0 +1,141 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigPrecedence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config")
+	require.NoError(t, ioutil.WriteFile(path, []byte(`
+pool_size = "6"
+port = "2000"
+stats_port = "3000"
+proxy_protocol = ["10.0.0.1"]
+`), 0600))
+
+	var c Config
+	setDefaults(&c)
+	require.NoError(t, c.Load(path))
+	require.NoError(t, c.LoadEnv([]string{
+		"CASHSHUFFLE_PORT=2001",
+		"CASHSHUFFLE_STATS_PORT=3001",
+		"CASHSHUFFLE_PROXY_PROTOCOL=10.0.0.2, 10.0.0.3",
+		"CASHSHUFFLE_CONFIG=" + path,
+		"HOME=/root",
+	}))
+
+	flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	flags.IntVarP(&c.StatsPort, "stats-port", "z", c.StatsPort, "")
+	flags.StringVarP(&configFile, "config", "", "", "")
+	require.NoError(t, flags.Parse([]string{"--stats-port", "3002", "--config", path}))
+	require.NoError(t, c.applyFlags(flags))
+
+	assert.Equal(t, 6, c.PoolSize)
+	assert.Equal(t, 2001, c.Port)
+	assert.Equal(t, 3002, c.StatsPort)
+	assert.Equal(t, []string{"10.0.0.2", "10.0.0.3"}, c.ProxyProtocol)
+	assert.Equal(t, defaultWebSocketPort, c.WebSocketPort)
+	assert.NoError(t, c.validate())
+}
+
+func TestConfigRejectsUnknownKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config")
+	require.NoError(t, ioutil.WriteFile(path, []byte(`pool_sise = "6"`), 0600))
+
+	var c Config
+	assert.Error(t, c.Load(path))
+	assert.Error(t, c.LoadEnv([]string{"CASHSHUFFLE_POOL_SIZ=6"}))
+	assert.Error(t, c.Load(filepath.Join(t.TempDir(), "missing")))
+}
+
+func TestConfigRejectsBadValues(t *testing.T) {
+	var c Config
+	assert.Error(t, c.LoadEnv([]string{"CASHSHUFFLE_POOL_SIZE=five"}))
+	assert.Error(t, c.LoadEnv([]string{"CASHSHUFFLE_TOR=maybe"}))
+
+	for _, bad := range []func(*Config){
+		func(c *Config) { c.PoolSize = -1 },
+		func(c *Config) { c.Port = 70000 },
+		func(c *Config) { c.BindIP = "localhost" },
+		func(c *Config) { c.WebSocketPath = "ws" },
+		func(c *Config) { c.StatsRateLimit = "fast" },
+		func(c *Config) { c.ResumeGracePeriod = "soon" },
+		func(c *Config) { c.ResumeGracePeriod = "-1s" },
+		func(c *Config) { c.FederationInterval = "5" },
+		func(c *Config) { c.WebSocketPingInterval = "often" },
+		func(c *Config) { c.Versions = []string{"new"} },
+	} {
+		var c Config
+		setDefaults(&c)
+		require.NoError(t, c.validate())
+
+		bad(&c)
+		assert.Error(t, c.validate(), "%+v", c)
+	}
+}
+
+func TestDataDir(t *testing.T) {
+	var c Config
+	configDir, err := c.configDir()
+	require.NoError(t, err)
+
+	dataDir, err := c.dataDir()
+	require.NoError(t, err)
+	assert.Equal(t, configDir, dataDir)
+
+	require.NoError(t, c.LoadEnv([]string{"CASHSHUFFLE_DATA_DIR=/var/lib/cashshuffle"}))
+	dataDir, err = c.dataDir()
+	require.NoError(t, err)
+	assert.Equal(t, "/var/lib/cashshuffle", dataDir)
+}
+
+func TestRatesPerListener(t *testing.T) {
+	var c Config
+	setDefaults(&c)
+	c.StatsRateLimit = "10-S"
+
+	r, err := getRates(&c)
+	require.NoError(t, err)
+	assert.Equal(t, int64(180), r.shuffle.Limit)
+	assert.Equal(t, int64(10), r.stats.Limit)
+	assert.Equal(t, int64(500), r.torShuffle.Limit)
+
+	c.TorWebSocketRateLimit = "fast"
+	_, err = getRates(&c)
+	assert.EqualError(t, err, "invalid tor_websocket_rate_limit: incorrect format 'fast'")
+}
+
+func TestWebsocketOptions(t *testing.T) {
+	var c Config
+	setDefaults(&c)
+	require.NoError(t, c.LoadEnv([]string{
+		"CASHSHUFFLE_WEBSOCKET_PING_INTERVAL=1m",
+		"CASHSHUFFLE_WEBSOCKET_ALLOWED_ORIGINS=https://a.example,https://b.example",
+	}))
+
+	opts, err := getWebsocketOptions(&c)
+	require.NoError(t, err)
+	assert.Equal(t, time.Minute, opts.PingInterval)
+	assert.Equal(t, 10*time.Second, opts.PongTimeout)
+	assert.False(t, opts.Compression)
+	assert.Equal(t, []string{"https://a.example", "https://b.example"}, opts.AllowedOrigins)
+
+	c.WebSocketPongTimeout = "soon"
+	_, err = getWebsocketOptions(&c)
+	assert.EqualError(t, err, `invalid websocket_pong_timeout: time: invalid duration "soon"`)
+
+	c.WebSocketPongTimeout = "0"
+	_, err = getWebsocketOptions(&c)
+	assert.Error(t, err)
+
+	c.WebSocketPingInterval = "0"
+	_, err = getWebsocketOptions(&c)
+	assert.NoError(t, err)
+}
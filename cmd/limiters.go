@@ -0,0 +1,108 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/cashshuffle/cashshuffle/server"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/ulule/limiter/v3"
+	"github.com/ulule/limiter/v3/drivers/store/memory"
+	sredis "github.com/ulule/limiter/v3/drivers/store/redis"
+)
+
+// limiters holds a rate limiter for each listener. Each limiter has its
+// own store, so connections to one listener do not count against another.
+type limiters struct {
+	shuffle      *server.RateLimiter
+	webSocket    *server.RateLimiter
+	stats        *server.RateLimiter
+	torShuffle   *server.RateLimiter
+	torWebSocket *server.RateLimiter
+	torStats     *server.RateLimiter
+}
+
+// rates holds the rate of each listener.
+type rates struct {
+	shuffle      limiter.Rate
+	webSocket    limiter.Rate
+	stats        limiter.Rate
+	torShuffle   limiter.Rate
+	torWebSocket limiter.Rate
+	torStats     limiter.Rate
+}
+
+// newLimiters creates the rate limiters with the rates in c. If client
+// is not nil, the counts are kept in Redis and shared with every server
+// using the same Redis prefix.
+func newLimiters(c *Config, client redis.UniversalClient) (*limiters, error) {
+	r, err := getRates(c)
+	if err != nil {
+		return nil, err
+	}
+
+	l := &limiters{}
+	for _, limit := range []struct {
+		name    string
+		rate    limiter.Rate
+		limiter **server.RateLimiter
+	}{
+		{"shuffle", r.shuffle, &l.shuffle},
+		{"websocket", r.webSocket, &l.webSocket},
+		{"stats", r.stats, &l.stats},
+		{"tor_shuffle", r.torShuffle, &l.torShuffle},
+		{"tor_websocket", r.torWebSocket, &l.torWebSocket},
+		{"tor_stats", r.torStats, &l.torStats},
+	} {
+		store := memory.NewStore()
+		if client != nil {
+			store, err = sredis.NewStoreWithOptions(client, limiter.StoreOptions{
+				Prefix: fmt.Sprintf("%s:limiter:%s", c.RedisPrefix, limit.name),
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		*limit.limiter = server.NewRateLimiter(store, limit.rate)
+	}
+
+	return l, nil
+}
+
+// setRates changes the rate of each limiter.
+func (l *limiters) setRates(r *rates) {
+	l.shuffle.SetRate(r.shuffle)
+	l.webSocket.SetRate(r.webSocket)
+	l.stats.SetRate(r.stats)
+	l.torShuffle.SetRate(r.torShuffle)
+	l.torWebSocket.SetRate(r.torWebSocket)
+	l.torStats.SetRate(r.torStats)
+}
+
+// getRates parses the rate of each listener.
+func getRates(c *Config) (*rates, error) {
+	r := &rates{}
+
+	for _, f := range []struct {
+		key       string
+		formatted string
+		rate      *limiter.Rate
+	}{
+		{"rate_limit", c.RateLimit, &r.shuffle},
+		{"websocket_rate_limit", c.WebSocketRateLimit, &r.webSocket},
+		{"stats_rate_limit", c.StatsRateLimit, &r.stats},
+		{"tor_rate_limit", c.TorRateLimit, &r.torShuffle},
+		{"tor_websocket_rate_limit", c.TorWebSocketRateLimit, &r.torWebSocket},
+		{"tor_stats_rate_limit", c.TorStatsRateLimit, &r.torStats},
+	} {
+		rate, err := limiter.NewRateFromFormatted(f.formatted)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %s", f.key, err)
+		}
+
+		*f.rate = rate
+	}
+
+	return r, nil
+}
@@ -1,17 +1,20 @@
 package cmd
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
+	"time"
 
 	"github.com/cashshuffle/cashshuffle/server"
 
+	"github.com/redis/go-redis/v9"
 	"github.com/spf13/cobra"
-	"github.com/ulule/limiter/v3"
-	"github.com/ulule/limiter/v3/drivers/store/memory"
 	"golang.org/x/crypto/acme/autocert"
 
 	log "github.com/sirupsen/logrus"
@@ -28,14 +31,33 @@ const (
 	defaultTorStatsPort     = 8081
 	defaultPoolSize         = 5
 	defaultTorBindIP        = "127.0.0.1"
-
-	ipRateLimit    = "180-M"
-	torIPRateLimit = "500-M"
+	defaultIPv4PrefixLength = 32
+	defaultIPv6PrefixLength = 64
+	defaultPoolSeparation   = "none"
+
+	defaultRateLimit             = "180-M"
+	defaultWebSocketRateLimit    = "180-M"
+	defaultStatsRateLimit        = "60-M"
+	defaultTorRateLimit          = "500-M"
+	defaultTorWebSocketRateLimit = "500-M"
+	defaultTorStatsRateLimit     = "60-M"
+	defaultRedisPrefix           = "cashshuffle"
+	defaultLogFormat             = "text"
+	defaultLogLevel              = "info"
+	defaultLogPrivacy            = "none"
+	defaultWebSocketPath         = server.DefaultWebsocketPath
+	defaultWebSocketPingInterval = "30s"
+	defaultWebSocketPongTimeout  = "10s"
+	defaultFederationInterval    = "5m"
+	defaultResumeGracePeriod     = "10s"
 )
 
 // Stores configuration data.
 var config Config
 
+// Keeps the onion service published while the server runs.
+var onionService *server.OnionService
+
 // MainCmd is the main command for Cobra.
 var MainCmd = &cobra.Command{
 	Use:   "cashshuffle",
@@ -47,53 +69,130 @@ var MainCmd = &cobra.Command{
 	},
 }
 
-func init() {
-	err := config.Load()
-	if err != nil {
-		bail(fmt.Errorf("failed to load configuration: %s", err))
-	}
+// Path of the config file set on the command line.
+var configFile string
 
+func init() {
 	prepareFlags()
 }
 
 func bail(err error) {
-	log.Errorf("[Error] Stopping server: %s\n", err)
+	log.WithField("bucket", "error").Errorf("Stopping server: %s\n", err)
 	os.Exit(1)
 }
 
-func prepareFlags() {
-	if config.Port == 0 {
-		config.Port = defaultPort
+// setDefaults fills in the settings that have not been configured.
+func setDefaults(c *Config) {
+	if c.Port == 0 {
+		c.Port = defaultPort
+	}
+
+	if c.WebSocketPort == 0 {
+		c.WebSocketPort = defaultWebSocketPort
+	}
+
+	if c.StatsPort == 0 {
+		c.StatsPort = defaultStatsPort
+	}
+
+	if c.TorBindIP == "" {
+		c.TorBindIP = defaultTorBindIP
+	}
+
+	if c.TorPort == 0 {
+		c.TorPort = defaultTorPort
+	}
+
+	if c.TorWebSocketPort == 0 {
+		c.TorWebSocketPort = defaultTorWebSocketPort
+	}
+
+	if c.TorStatsPort == 0 {
+		c.TorStatsPort = defaultTorStatsPort
+	}
+
+	if c.PoolSize == 0 {
+		c.PoolSize = defaultPoolSize
+	}
+
+	if c.IPv4PrefixLength == 0 {
+		c.IPv4PrefixLength = defaultIPv4PrefixLength
+	}
+
+	if c.IPv6PrefixLength == 0 {
+		c.IPv6PrefixLength = defaultIPv6PrefixLength
 	}
 
-	if config.WebSocketPort == 0 {
-		config.WebSocketPort = defaultWebSocketPort
+	if c.PoolSeparation == "" {
+		c.PoolSeparation = defaultPoolSeparation
 	}
 
-	if config.StatsPort == 0 {
-		config.StatsPort = defaultStatsPort
+	if c.RateLimit == "" {
+		c.RateLimit = defaultRateLimit
 	}
 
-	if config.TorBindIP == "" {
-		config.TorBindIP = defaultTorBindIP
+	if c.WebSocketRateLimit == "" {
+		c.WebSocketRateLimit = defaultWebSocketRateLimit
 	}
 
-	if config.TorPort == 0 {
-		config.TorPort = defaultTorPort
+	if c.StatsRateLimit == "" {
+		c.StatsRateLimit = defaultStatsRateLimit
 	}
 
-	if config.TorWebSocketPort == 0 {
-		config.TorWebSocketPort = defaultTorWebSocketPort
+	if c.TorRateLimit == "" {
+		c.TorRateLimit = defaultTorRateLimit
 	}
 
-	if config.TorStatsPort == 0 {
-		config.TorStatsPort = defaultTorStatsPort
+	if c.TorWebSocketRateLimit == "" {
+		c.TorWebSocketRateLimit = defaultTorWebSocketRateLimit
 	}
 
-	if config.PoolSize == 0 {
-		config.PoolSize = defaultPoolSize
+	if c.TorStatsRateLimit == "" {
+		c.TorStatsRateLimit = defaultTorStatsRateLimit
 	}
 
+	if c.RedisPrefix == "" {
+		c.RedisPrefix = defaultRedisPrefix
+	}
+
+	if c.LogFormat == "" {
+		c.LogFormat = defaultLogFormat
+	}
+
+	if c.LogLevel == "" {
+		c.LogLevel = defaultLogLevel
+	}
+
+	if c.LogPrivacy == "" {
+		c.LogPrivacy = defaultLogPrivacy
+	}
+
+	if c.WebSocketPath == "" {
+		c.WebSocketPath = defaultWebSocketPath
+	}
+
+	if c.WebSocketPingInterval == "" {
+		c.WebSocketPingInterval = defaultWebSocketPingInterval
+	}
+
+	if c.WebSocketPongTimeout == "" {
+		c.WebSocketPongTimeout = defaultWebSocketPongTimeout
+	}
+
+	if c.FederationInterval == "" {
+		c.FederationInterval = defaultFederationInterval
+	}
+
+	if c.ResumeGracePeriod == "" {
+		c.ResumeGracePeriod = defaultResumeGracePeriod
+	}
+}
+
+func prepareFlags() {
+	setDefaults(&config)
+
+	MainCmd.PersistentFlags().StringVarP(
+		&configFile, "config", "", "", "path to the config file (default ~/.cashshuffle/config)")
 	MainCmd.PersistentFlags().StringVarP(
 		&config.Cert, "cert", "c", config.Cert, "path to server.crt for TLS")
 	MainCmd.PersistentFlags().StringVarP(
@@ -106,6 +205,10 @@ func prepareFlags() {
 		&config.WebSocketPort, "websocket-port", "w", config.WebSocketPort, "websocket port")
 	MainCmd.PersistentFlags().IntVarP(
 		&config.StatsPort, "stats-port", "z", config.StatsPort, "stats server port")
+	MainCmd.PersistentFlags().IntVarP(
+		&config.HTTPPort, "http-port", "", config.HTTPPort, "port serving both websockets and stats over HTTP")
+	MainCmd.PersistentFlags().StringVarP(
+		&config.WebSocketPath, "websocket-path", "", config.WebSocketPath, "path of websocket connections on the http port")
 	MainCmd.PersistentFlags().IntVarP(
 		&config.PoolSize, "pool-size", "s", config.PoolSize, "pool size")
 	MainCmd.PersistentFlags().BoolVarP(
@@ -124,23 +227,116 @@ func prepareFlags() {
 		&config.TorWebSocketPort, "tor-websocket-port", "", config.TorWebSocketPort, "tor websocket port")
 	MainCmd.PersistentFlags().IntVarP(
 		&config.TorStatsPort, "tor-stats-port", "", config.TorStatsPort, "tor stats server port")
+	MainCmd.PersistentFlags().IntVarP(
+		&config.TorHTTPPort, "tor-http-port", "", config.TorHTTPPort, "tor port serving both websockets and stats over HTTP")
+	MainCmd.PersistentFlags().BoolVarP(
+		&config.Drain, "drain", "", config.Drain, "refuse new players and report not ready, so that running pools can finish")
+	MainCmd.PersistentFlags().IntVarP(
+		&config.ReadyCertDays, "ready-cert-days", "", config.ReadyCertDays, "days the TLS certificate must still be valid for /readyz to report ready")
+	MainCmd.PersistentFlags().BoolVarP(
+		&config.RoundHistory, "round-history", "", config.RoundHistory, "serve the history of recent rounds on /v1/rounds")
+	MainCmd.PersistentFlags().StringSliceVarP(
+		&config.TorProxyProtocol, "tor-proxy-protocol", "", config.TorProxyProtocol, "trust PROXY protocol headers with tor circuit IDs from these IPs or CIDRs, needed to ban tor players")
+	MainCmd.PersistentFlags().StringVarP(
+		&config.TorControl, "tor-control", "", config.TorControl, "tor control port address to publish the tor listeners as an onion service")
+	MainCmd.PersistentFlags().StringVarP(
+		&config.TorControlPass, "tor-control-password", "", config.TorControlPass, "tor control port password (cookie auth is used if empty)")
+	MainCmd.PersistentFlags().IntVarP(
+		&config.IPv4PrefixLength, "ipv4-prefix-length", "", config.IPv4PrefixLength, "IPv4 prefix length bans and pool separation apply to")
+	MainCmd.PersistentFlags().IntVarP(
+		&config.IPv6PrefixLength, "ipv6-prefix-length", "", config.IPv6PrefixLength, "IPv6 prefix length bans and pool separation apply to")
+	MainCmd.PersistentFlags().StringSliceVarP(
+		&config.ProxyProtocol, "proxy-protocol", "", config.ProxyProtocol, "trust PROXY protocol headers from these IPs or CIDRs")
+	MainCmd.PersistentFlags().StringSliceVarP(
+		&config.TrustedProxies, "trusted-proxies", "", config.TrustedProxies, "trust X-Forwarded-For headers from these IPs or CIDRs")
+	MainCmd.PersistentFlags().IntVarP(
+		&config.MaxConnections, "max-connections", "", config.MaxConnections, "maximum concurrent connections (0 for no limit)")
+	MainCmd.PersistentFlags().IntVarP(
+		&config.MaxConnectionsPerIP, "max-connections-per-ip", "", config.MaxConnectionsPerIP, "maximum concurrent connections per IP (0 for no limit)")
+	MainCmd.PersistentFlags().IntVarP(
+		&config.MaxConnectionsPerPrefix, "max-connections-per-prefix", "", config.MaxConnectionsPerPrefix, "maximum concurrent connections per IP prefix (0 for no limit)")
+	MainCmd.PersistentFlags().IntVarP(
+		&config.MaxPoolsPerIP, "max-pools-per-ip", "", config.MaxPoolsPerIP, "maximum pools an IP prefix can join at once (0 for no limit)")
+	MainCmd.PersistentFlags().StringVarP(
+		&config.PoolSeparation, "pool-separation", "", config.PoolSeparation, "keep players from the same address out of a pool (none, ip or prefix)")
+	MainCmd.PersistentFlags().StringVarP(
+		&config.ResumeGracePeriod, "resume-grace-period", "", config.ResumeGracePeriod, "how long a disconnected player's slot is held for it to resume (0 to disable)")
+	MainCmd.PersistentFlags().StringSliceVarP(
+		&config.Versions, "versions", "", config.Versions, "protocol versions told to clients and federation peers as supported (default all)")
+	MainCmd.PersistentFlags().StringVarP(
+		&config.RateLimit, "rate-limit", "", config.RateLimit, "shuffle connections allowed per IP (e.g. 180-M for 180 per minute)")
+	MainCmd.PersistentFlags().StringVarP(
+		&config.WebSocketRateLimit, "websocket-rate-limit", "", config.WebSocketRateLimit, "websocket connections allowed per IP")
+	MainCmd.PersistentFlags().StringVarP(
+		&config.StatsRateLimit, "stats-rate-limit", "", config.StatsRateLimit, "stats requests allowed per IP")
+	MainCmd.PersistentFlags().StringVarP(
+		&config.TorRateLimit, "tor-rate-limit", "", config.TorRateLimit, "tor shuffle connections allowed per IP")
+	MainCmd.PersistentFlags().StringVarP(
+		&config.TorWebSocketRateLimit, "tor-websocket-rate-limit", "", config.TorWebSocketRateLimit, "tor websocket connections allowed per IP")
+	MainCmd.PersistentFlags().StringVarP(
+		&config.TorStatsRateLimit, "tor-stats-rate-limit", "", config.TorStatsRateLimit, "tor stats requests allowed per IP")
+	MainCmd.PersistentFlags().StringVarP(
+		&config.RedisURL, "redis-url", "", config.RedisURL, "share rate limits and bans with other servers through Redis (e.g. redis://localhost:6379/0)")
+	MainCmd.PersistentFlags().StringVarP(
+		&config.RedisPrefix, "redis-prefix", "", config.RedisPrefix, "prefix for Redis keys")
+	MainCmd.PersistentFlags().StringVarP(
+		&config.LogFormat, "log-format", "", config.LogFormat, "log format (text or json)")
+	MainCmd.PersistentFlags().StringVarP(
+		&config.LogLevel, "log-level", "", config.LogLevel, "log level (error, warn, info or debug)")
+	MainCmd.PersistentFlags().StringSliceVarP(
+		&config.LogDisableBuckets, "log-disable-buckets", "", config.LogDisableBuckets, "log buckets to leave out (announce, ban, blame, broadcast, communication, direct_message, federation, listener, round)")
+	MainCmd.PersistentFlags().StringVarP(
+		&config.LogPrivacy, "log-privacy", "", config.LogPrivacy, "how IPs and verification keys are logged (none, hash or truncate)")
+	MainCmd.PersistentFlags().BoolVarP(
+		&config.LogNoLinkage, "log-no-linkage", "", config.LogNoLinkage, "never log IPs and verification keys together")
+	MainCmd.PersistentFlags().StringVarP(
+		&config.WebSocketPingInterval, "websocket-ping-interval", "", config.WebSocketPingInterval, "how often websocket clients are pinged (0 to disable)")
+	MainCmd.PersistentFlags().StringVarP(
+		&config.WebSocketPongTimeout, "websocket-pong-timeout", "", config.WebSocketPongTimeout, "how long websocket clients have to answer a ping")
+	MainCmd.PersistentFlags().BoolVarP(
+		&config.WebSocketCompression, "websocket-compression", "", config.WebSocketCompression, "enable websocket compression (permessage-deflate)")
+	MainCmd.PersistentFlags().StringSliceVarP(
+		&config.WebSocketAllowedOrigins, "websocket-allowed-origins", "", config.WebSocketAllowedOrigins, "origins browsers may open websockets from (default all)")
+	MainCmd.PersistentFlags().BoolVarP(
+		&config.Federation, "federation", "", config.Federation, "announce this server to federation peers and serve a signed /servers list")
+	MainCmd.PersistentFlags().StringVarP(
+		&config.FederationHost, "federation-host", "", config.FederationHost, "public hostname announced to federation peers (default the auto-cert hostname)")
+	MainCmd.PersistentFlags().StringSliceVarP(
+		&config.FederationPeers, "federation-peers", "", config.FederationPeers, "base URLs of the servers or directories to announce to (e.g. https://peer.example.com:8080)")
+	MainCmd.PersistentFlags().StringVarP(
+		&config.FederationInterval, "federation-interval", "", config.FederationInterval, "how often to announce to peers and probe other servers")
+	MainCmd.PersistentFlags().StringSliceVarP(
+		&config.FederationTrustedKeys, "federation-trusted-keys", "", config.FederationTrustedKeys, "public keys of the servers allowed to announce to this one (default none)")
+	MainCmd.PersistentFlags().StringVarP(
+		&config.SigningKey, "signing-key", "", config.SigningKey, "path to the key stats, packets and announcements are signed with (default signing.key in the data directory)")
+	MainCmd.PersistentFlags().StringVarP(
+		&config.DataDir, "data-dir", "", config.DataDir, "directory the signing key, onion key and Let's Encrypt certificates are kept in (default ~/.cashshuffle)")
 }
 
 // Where all the work happens.
 func performCommand(cmd *cobra.Command, args []string) chan error {
-	errChan := make(chan error)
+	errChan := make(chan error, 1)
 
 	if config.DisplayVersion {
 		fmt.Printf("%s %s\n", appName, version)
 		os.Exit(0)
 	}
 
+	c, err := buildConfig(cmd.Flags())
+	if err != nil {
+		errChan <- fmt.Errorf("failed to load configuration: %s", err)
+		return errChan
+	}
+	config = c
+
 	if config.AutoCert != "" && (config.Cert != "" || config.Key != "") {
 		errChan <- errors.New("can't specify auto-cert and key/cert")
 		return errChan
 	}
 
 	t := server.NewTracker(config.PoolSize, config.Port, config.WebSocketPort, config.TorPort, config.TorWebSocketPort)
+	h := server.NewHealth()
+	t.SetHealth(h)
 
 	m, err := getLetsEncryptManager(errChan)
 	if err != nil {
@@ -148,89 +344,312 @@ func performCommand(cmd *cobra.Command, args []string) chan error {
 		return errChan
 	}
 
-	limit, torLimit, err := getLimiters()
+	if m != nil {
+		h.SetAutocert(m, config.AutoCert)
+	}
+
+	client, err := getRedisClient()
+	if err != nil {
+		errChan <- err
+		return errChan
+	}
+
+	if client != nil {
+		t.SetBanStore(server.NewRedisBanStore(client, config.RedisPrefix))
+	}
+
+	l, err := newLimiters(&config, client)
 	if err != nil {
 		errChan <- err
 		return errChan
 	}
 
+	if err := applySettings(t, h, l, &config); err != nil {
+		errChan <- err
+		return errChan
+	}
+
+	go watchReload(cmd.Flags(), t, h, l)
+
+	wsOpts, err := getWebsocketOptions(&config)
+	if err != nil {
+		errChan <- err
+		return errChan
+	}
+
+	trustedProxies, err := server.ParseTrustedProxies(config.TrustedProxies)
+	if err != nil {
+		errChan <- err
+		return errChan
+	}
+
+	if config.Tor && config.TorControl != "" {
+		if err := publishOnion(t); err != nil {
+			errChan <- err
+			return errChan
+		}
+	}
+
+	// the default key is optional, so a config directory that can not be
+	// written does not keep the server from starting
+	id, err := getIdentity(t)
+	switch {
+	case err == nil:
+		t.SetIdentity(id)
+	case config.SigningKey != "" || config.Federation:
+		errChan <- err
+		return errChan
+	default:
+		t.DisableSigning(err)
+	}
+
+	if config.Federation {
+		f, err := getFederation(id)
+		if err != nil {
+			errChan <- err
+			return errChan
+		}
+
+		server.EnableFederation(context.Background(), f, h)
+	}
+
+	// every listener is counted before any starts, so the health
+	// endpoints only report healthy once all of them are bound.
+	var listeners []func() error
+
 	// enable stats if port specified
 	if config.StatsPort > 0 {
-		go func() {
-			errChan <- server.StartStatsServer(config.BindIP, config.StatsPort, config.Cert, config.Key, t, m, false, limit)
-		}()
+		listeners = append(listeners, func() error {
+			return server.StartStatsServer(config.BindIP, config.StatsPort, config.Cert, config.Key, t, h, m, false, l.stats, config.ProxyProtocol, trustedProxies)
+		})
 	}
 
 	if config.Tor && config.TorStatsPort > 0 {
-		go func() {
-			errChan <- server.StartStatsServer(config.TorBindIP, config.TorStatsPort, "", "", t, nil, true, torLimit)
-		}()
+		listeners = append(listeners, func() error {
+			return server.StartStatsServer(config.TorBindIP, config.TorStatsPort, "", "", t, h, nil, true, l.torStats, config.TorProxyProtocol, nil)
+		})
 	}
 
 	// enable websocket port if specified.
 	if config.WebSocketPort > 0 {
-		go func() {
-			errChan <- server.StartWebsocket(config.BindIP, config.WebSocketPort, config.Cert, config.Key, config.Debug, t, m, false, limit)
-		}()
+		listeners = append(listeners, func() error {
+			return server.StartWebsocket(config.BindIP, config.WebSocketPort, config.Cert, config.Key, t, h, m, false, l.webSocket, config.ProxyProtocol, trustedProxies, wsOpts)
+		})
 	}
 
 	if config.Tor && config.TorWebSocketPort > 0 {
-		go func() {
-			errChan <- server.StartWebsocket(config.TorBindIP, config.TorWebSocketPort, "", "", config.Debug, t, nil, true, torLimit)
-		}()
+		listeners = append(listeners, func() error {
+			return server.StartWebsocket(config.TorBindIP, config.TorWebSocketPort, "", "", t, h, nil, true, l.torWebSocket, config.TorProxyProtocol, nil, wsOpts)
+		})
+	}
+
+	// enable the shared http port if specified.
+	if config.HTTPPort > 0 {
+		listeners = append(listeners, func() error {
+			return server.StartHTTPServer(config.BindIP, config.HTTPPort, config.Cert, config.Key, t, h, m, false, l.webSocket, l.stats, config.ProxyProtocol, trustedProxies, config.WebSocketPath, wsOpts)
+		})
+	}
+
+	if config.Tor && config.TorHTTPPort > 0 {
+		listeners = append(listeners, func() error {
+			return server.StartHTTPServer(config.TorBindIP, config.TorHTTPPort, "", "", t, h, nil, true, l.torWebSocket, l.torStats, config.TorProxyProtocol, nil, config.WebSocketPath, wsOpts)
+		})
 	}
 
 	// enable tor server if specified.
 	if config.Tor {
-		go func() {
-			errChan <- server.Start(config.TorBindIP, config.TorPort, "", "", config.Debug, t, nil, true, torLimit)
-		}()
+		listeners = append(listeners, func() error {
+			return server.Start(config.TorBindIP, config.TorPort, "", "", t, h, nil, true, l.torShuffle, config.TorProxyProtocol)
+		})
 	}
 
-	go func() {
-		errChan <- server.Start(config.BindIP, config.Port, config.Cert, config.Key, config.Debug, t, m, false, limit)
-	}()
+	listeners = append(listeners, func() error {
+		return server.Start(config.BindIP, config.Port, config.Cert, config.Key, t, h, m, false, l.shuffle, config.ProxyProtocol)
+	})
+
+	h.ExpectListeners(len(listeners))
+	for _, start := range listeners {
+		go func(start func() error) {
+			errChan <- start()
+		}(start)
+	}
 
 	return errChan
 }
 
-func getLimiters() (*limiter.Limiter, *limiter.Limiter, error) {
-	var rate limiter.Rate
+// getWebsocketOptions parses the websocket settings.
+func getWebsocketOptions(c *Config) (server.WebsocketOptions, error) {
+	opts := server.WebsocketOptions{
+		Compression:    c.WebSocketCompression,
+		AllowedOrigins: c.WebSocketAllowedOrigins,
+	}
+
+	for _, f := range []struct {
+		key       string
+		formatted string
+		duration  *time.Duration
+	}{
+		{"websocket_ping_interval", c.WebSocketPingInterval, &opts.PingInterval},
+		{"websocket_pong_timeout", c.WebSocketPongTimeout, &opts.PongTimeout},
+	} {
+		d, err := time.ParseDuration(f.formatted)
+		if err != nil {
+			return opts, fmt.Errorf("invalid %s: %s", f.key, err)
+		}
+
+		if d < 0 {
+			return opts, fmt.Errorf("invalid %s: %s", f.key, f.formatted)
+		}
+
+		*f.duration = d
+	}
+
+	if opts.PingInterval > 0 && opts.PongTimeout == 0 {
+		return opts, errors.New("invalid websocket_pong_timeout: must be set when pings are enabled")
+	}
+
+	return opts, nil
+}
 
-	rate, err := limiter.NewRateFromFormatted(ipRateLimit)
+// getIdentity loads the signing key of the server and describes the
+// server to clients. The key is kept in the data directory unless
+// signing_key is set. Only a set signing_key or federation requires it.
+func getIdentity(t *server.Tracker) (*server.Identity, error) {
+	keyPath := config.SigningKey
+	if keyPath == "" {
+		dataDir, err := config.dataDir()
+		if err != nil {
+			return nil, err
+		}
+
+		keyPath = filepath.Join(dataDir, "signing.key")
+	}
+
+	key, err := server.LoadSigningKey(keyPath)
+	if err != nil {
+		return nil, err
+	}
+
+	a := server.ServerAnnouncement{
+		Host:                 config.FederationHost,
+		TLS:                  config.Cert != "" || config.AutoCert != "",
+		ShufflePort:          config.Port,
+		ShuffleWebSocketPort: config.WebSocketPort,
+		StatsPort:            config.StatsPort,
+		HTTPPort:             config.HTTPPort,
+		ServerVersion:        version,
+	}
+
+	if a.Host == "" {
+		a.Host = config.AutoCert
+	}
+
+	if config.HTTPPort > 0 {
+		a.WebSocketPath = config.WebSocketPath
+	}
+
+	if config.Tor {
+		a.TorShufflePort = config.TorPort
+		a.TorShuffleWebSocketPort = config.TorWebSocketPort
+		a.TorStatsPort = config.TorStatsPort
+		a.TorHTTPPort = config.TorHTTPPort
+	}
+
+	return server.NewIdentity(key, a, t), nil
+}
+
+// getVersions parses the protocol versions the server supports.
+func getVersions(c *Config) ([]uint64, error) {
+	var versions []uint64
+	for _, v := range c.Versions {
+		n, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid versions: %s", v)
+		}
+
+		versions = append(versions, n)
+	}
+
+	return versions, nil
+}
+
+// getFederation sets up federation for the server with identity id.
+func getFederation(id *server.Identity) (*server.Federation, error) {
+	interval, err := time.ParseDuration(config.FederationInterval)
+	if err != nil {
+		return nil, fmt.Errorf("invalid federation_interval: %s", err)
+	}
+
+	return server.NewFederation(server.FederationOptions{
+		Identity:    id,
+		Peers:       config.FederationPeers,
+		Interval:    interval,
+		TrustedKeys: config.FederationTrustedKeys,
+	})
+}
+
+// publishOnion publishes the tor listeners as an onion service through
+// the tor control port. The onion key is kept in the data directory.
+func publishOnion(t *server.Tracker) error {
+	dataDir, err := config.dataDir()
 	if err != nil {
-		return nil, nil, err
+		return err
 	}
 
-	torRate, err := limiter.NewRateFromFormatted(torIPRateLimit)
+	var ports []server.OnionPort
+	for _, port := range []int{config.TorPort, config.TorWebSocketPort, config.TorStatsPort, config.TorHTTPPort} {
+		if port > 0 {
+			ports = append(ports, server.OnionPort{
+				VirtualPort: port,
+				Target:      net.JoinHostPort(config.TorBindIP, strconv.Itoa(port)),
+			})
+		}
+	}
+
+	onionService, err = server.PublishOnion(config.TorControl, config.TorControlPass, filepath.Join(dataDir, "onion.key"), ports)
 	if err != nil {
-		return nil, nil, err
+		return err
 	}
 
-	limit := limiter.New(memory.NewStore(), rate, limiter.WithTrustForwardHeader(true))
-	torLimit := limiter.New(memory.NewStore(), torRate)
+	t.SetOnionAddress(onionService.Address)
 
-	return limit, torLimit, nil
+	return nil
 }
 
-func getLetsEncryptManager(errChan chan error) (*autocert.Manager, error) {
-	configDir, err := config.configDir()
+// getRedisClient connects to Redis if a Redis URL is configured.
+func getRedisClient() (redis.UniversalClient, error) {
+	if config.RedisURL == "" {
+		return nil, nil
+	}
+
+	opts, err := redis.ParseURL(config.RedisURL)
 	if err != nil {
 		return nil, err
 	}
 
-	certDir := filepath.Join(configDir, "certs")
-	if _, err := os.Stat(certDir); os.IsNotExist(err) {
-		err = os.MkdirAll(certDir, 0755)
-		if err != nil {
-			return nil, err
-		}
+	client := redis.NewClient(opts)
+	if err := client.Ping(context.Background()).Err(); err != nil {
+		return nil, fmt.Errorf("unable to connect to redis: %s", err)
 	}
 
+	return client, nil
+}
+
+// getLetsEncryptManager sets up auto-cert. The certificates are kept in
+// the certs directory of the data directory, which the cache creates once
+// it has a certificate to store.
+func getLetsEncryptManager(errChan chan error) (*autocert.Manager, error) {
 	if config.AutoCert == "" {
 		return nil, nil
 	}
 
+	dataDir, err := config.dataDir()
+	if err != nil {
+		return nil, err
+	}
+
+	certDir := filepath.Join(dataDir, "certs")
+
 	m := &autocert.Manager{
 		Cache:      autocert.DirCache(certDir),
 		Prompt:     autocert.AcceptTOS,
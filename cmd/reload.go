@@ -0,0 +1,125 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/cashshuffle/cashshuffle/server"
+
+	"github.com/spf13/pflag"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// watchReload reloads the configuration and TLS certificates every time
+// the process receives SIGHUP.
+func watchReload(flags *pflag.FlagSet, t *server.Tracker, h *server.Health, l *limiters) {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, syscall.SIGHUP)
+
+	for range c {
+		if err := reload(flags, t, h, l); err != nil {
+			log.WithField("bucket", "reload").Errorf("Keeping previous configuration: %s\n", err)
+			continue
+		}
+
+		log.WithField("bucket", "reload").Info("Configuration reloaded\n")
+	}
+}
+
+// reload re-reads the config file and TLS certificates and applies the
+// settings that can change without dropping connections. Flags given on
+// the command line and environment variables still override the config
+// file.
+func reload(flags *pflag.FlagSet, t *server.Tracker, h *server.Health, l *limiters) error {
+	c, err := buildConfig(flags)
+	if err != nil {
+		return err
+	}
+
+	if err := applySettings(t, h, l, &c); err != nil {
+		return err
+	}
+
+	return server.ReloadCertificates()
+}
+
+// applySettings applies the settings that can change while the server
+// is running.
+func applySettings(t *server.Tracker, h *server.Health, l *limiters, c *Config) error {
+	separation, err := server.ParsePoolSeparation(c.PoolSeparation)
+	if err != nil {
+		return err
+	}
+
+	r, err := getRates(c)
+	if err != nil {
+		return err
+	}
+
+	privacy, err := server.ParsePrivacyMode(c.LogPrivacy)
+	if err != nil {
+		return err
+	}
+
+	resumeGracePeriod, err := time.ParseDuration(c.ResumeGracePeriod)
+	if err != nil {
+		return fmt.Errorf("invalid resume_grace_period: %s", err)
+	}
+
+	versions, err := getVersions(c)
+	if err != nil {
+		return err
+	}
+
+	level := c.LogLevel
+	if c.Debug {
+		level = "debug"
+	}
+
+	err = server.ConfigureLogging(server.LogOptions{
+		Format:          c.LogFormat,
+		Level:           level,
+		DisabledBuckets: c.LogDisableBuckets,
+		Privacy:         privacy,
+		NoLinkage:       c.LogNoLinkage,
+	})
+	if err != nil {
+		return err
+	}
+
+	limits := server.ConnectionLimits{
+		MaxConnections:          c.MaxConnections,
+		MaxConnectionsPerIP:     c.MaxConnectionsPerIP,
+		MaxConnectionsPerPrefix: c.MaxConnectionsPerPrefix,
+		MaxPoolsPerIP:           c.MaxPoolsPerIP,
+	}
+
+	if err := t.SetPrefixLengths(c.IPv4PrefixLength, c.IPv6PrefixLength); err != nil {
+		return err
+	}
+
+	if err := t.SetConnectionLimits(limits); err != nil {
+		return err
+	}
+
+	if err := t.SetResumeGracePeriod(resumeGracePeriod); err != nil {
+		return err
+	}
+
+	if err := t.SetPoolSize(c.PoolSize); err != nil {
+		return err
+	}
+
+	t.SetPoolSeparation(separation)
+	t.SetRoundHistory(c.RoundHistory)
+	t.SetVersions(versions)
+	l.setRates(r)
+	h.SetDraining(c.Drain)
+	h.SetReadyCertDays(c.ReadyCertDays)
+
+	return nil
+}
@@ -1,23 +1,41 @@
 module github.com/cashshuffle/cashshuffle
 
-go 1.12
+go 1.20
 
 require (
+	github.com/alicebob/miniredis/v2 v2.30.4
 	github.com/avast/retry-go v3.0.0+incompatible
-	github.com/go-redis/redis/v8 v8.3.3 // indirect
+	github.com/decred/dcrd/dcrec/secp256k1/v4 v4.2.0
 	github.com/golang/protobuf v1.5.3
+	github.com/gorilla/websocket v1.5.3
 	github.com/mitchellh/go-homedir v1.1.0
 	github.com/nats-io/nuid v1.0.1
-	github.com/shurcooL/sanitized_anchor_name v1.0.0 // indirect
+	github.com/pires/go-proxyproto v0.6.2
+	github.com/redis/go-redis/v9 v9.0.5
 	github.com/sirupsen/logrus v1.9.3
 	github.com/spf13/cobra v1.7.0
-	github.com/spf13/viper v1.7.0 // indirect
+	github.com/spf13/pflag v1.0.5
 	github.com/stretchr/testify v1.8.4
-	github.com/ugorji/go v1.1.7 // indirect
 	github.com/ulule/limiter/v3 v3.11.2
 	github.com/zquestz/go-ucl v0.0.0-20220615095619-8a3686d7543a
 	golang.org/x/crypto v0.14.0
-	golang.org/x/net v0.17.0
-	google.golang.org/genproto v0.0.0-20200526211855-cb27e3aa2013 // indirect
 	google.golang.org/protobuf v1.31.0
 )
+
+require (
+	github.com/alicebob/gopher-json v0.0.0-20200520072559-a9ecdc9d1d3a // indirect
+	github.com/cespare/xxhash/v2 v2.2.0 // indirect
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/dgryski/go-rendezvous v0.0.0-20200823014737-9f7001d12a5f // indirect
+	github.com/pkg/errors v0.9.1 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	github.com/shurcooL/sanitized_anchor_name v1.0.0 // indirect
+	github.com/spf13/viper v1.7.0 // indirect
+	github.com/ugorji/go v1.1.7 // indirect
+	github.com/yuin/gopher-lua v1.1.0 // indirect
+	golang.org/x/net v0.17.0 // indirect
+	golang.org/x/sys v0.13.0 // indirect
+	golang.org/x/text v0.13.0 // indirect
+	google.golang.org/genproto v0.0.0-20200526211855-cb27e3aa2013 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
@@ -0,0 +1,214 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/nats-io/nuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// BanStore keeps ban scores and deny matches outside of the process, so
+// that servers sharing a store also share bans.
+type BanStore interface {
+	// BanScore returns the current ban score of a ban key.
+	BanScore(key string) (uint32, error)
+
+	// IncreaseBanScore increases the ban score of a ban key. Each
+	// increase expires after banTime.
+	IncreaseBanScore(key string) error
+
+	// DenyMatch keeps two ban keys out of the same pool for denyIPTime.
+	DenyMatch(a string, b string) error
+
+	// Bans returns the ban score of key and which of others it may not
+	// share a pool with.
+	Bans(key string, others []string) (uint32, map[string]bool, error)
+}
+
+// RedisBanStore is a BanStore backed by Redis.
+type RedisBanStore struct {
+	client redis.UniversalClient
+	prefix string
+}
+
+// NewRedisBanStore creates a BanStore that keeps its keys in Redis
+// under prefix.
+func NewRedisBanStore(client redis.UniversalClient, prefix string) *RedisBanStore {
+	return &RedisBanStore{
+		client: client,
+		prefix: prefix,
+	}
+}
+
+// BanScore implements BanStore. Each increase is a member of a sorted set
+// scored by its expiry time, so expired increases are not counted.
+func (s *RedisBanStore) BanScore(key string) (uint32, error) {
+	count, err := s.banCount(context.Background(), s.client, key).Result()
+	if err != nil {
+		return 0, err
+	}
+
+	return uint32(count) * banScoreTick, nil
+}
+
+// banCount counts the increases of the ban score of key that have not
+// expired.
+func (s *RedisBanStore) banCount(ctx context.Context, c redis.Cmdable, key string) *redis.IntCmd {
+	now := strconv.FormatInt(time.Now().UnixNano(), 10)
+
+	return c.ZCount(ctx, s.banKey(key), "("+now, "+inf")
+}
+
+// IncreaseBanScore implements BanStore.
+func (s *RedisBanStore) IncreaseBanScore(key string) error {
+	ctx := context.Background()
+	now := time.Now()
+
+	_, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZRemRangeByScore(ctx, s.banKey(key), "-inf", strconv.FormatInt(now.UnixNano(), 10))
+		pipe.ZAdd(ctx, s.banKey(key), redis.Z{
+			Score:  float64(now.Add(banTime).UnixNano()),
+			Member: nuid.Next(),
+		})
+		pipe.Expire(ctx, s.banKey(key), banTime)
+
+		return nil
+	})
+
+	return err
+}
+
+// DenyMatch implements BanStore.
+func (s *RedisBanStore) DenyMatch(a string, b string) error {
+	return s.client.Set(context.Background(), s.denyKey(newIPPair(a, b)), 1, denyIPTime).Err()
+}
+
+// Bans implements BanStore. The ban score and deny matches are read in
+// one round trip.
+func (s *RedisBanStore) Bans(key string, others []string) (uint32, map[string]bool, error) {
+	ctx := context.Background()
+
+	var count *redis.IntCmd
+	exists := make(map[string]*redis.IntCmd, len(others))
+
+	_, err := s.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		count = s.banCount(ctx, pipe, key)
+		for _, other := range others {
+			exists[other] = pipe.Exists(ctx, s.denyKey(newIPPair(key, other)))
+		}
+
+		return nil
+	})
+	if err != nil {
+		return 0, nil, err
+	}
+
+	denied := make(map[string]bool)
+	for other, cmd := range exists {
+		if cmd.Val() > 0 {
+			denied[other] = true
+		}
+	}
+
+	return uint32(count.Val()) * banScoreTick, denied, nil
+}
+
+// banKey returns the Redis key holding the ban score of a ban key.
+func (s *RedisBanStore) banKey(key string) string {
+	return fmt.Sprintf("%s:ban:%s", s.prefix, key)
+}
+
+// denyKey returns the Redis key marking a denied pair.
+func (s *RedisBanStore) denyKey(pair ipPair) string {
+	return fmt.Sprintf("%s:deny:%s|%s", s.prefix, pair.left, pair.right)
+}
+
+// SetBanStore keeps ban scores and deny matches in store instead of in
+// the tracker.
+func (t *Tracker) SetBanStore(store BanStore) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.banStore = store
+}
+
+// storedBans is the ban state of a registering player read from the ban
+// store.
+type storedBans struct {
+	score  uint32
+	denied map[string]bool
+}
+
+// storedBans reads the ban score of a registering player from the ban
+// store, along with the players in pools it may not share a pool with.
+// The store is read without holding the mutex, so players that join
+// pools before it is taken again are not checked against deny matches.
+// Nil is returned if there is no ban store.
+func (t *Tracker) storedBans(p *PlayerData) *storedBans {
+	t.mutex.RLock()
+
+	store := t.banStore
+	if store == nil {
+		t.mutex.RUnlock()
+		return nil
+	}
+
+	key := t.banKey(p)
+
+	var others []string
+	for _, pool := range t.pools {
+		for _, other := range pool.players {
+			others = append(others, t.banKey(other))
+		}
+	}
+
+	t.mutex.RUnlock()
+
+	score, denied, err := store.Bans(key, others)
+	if err != nil {
+		logBan.Errorf("Unable to get bans for %s: %s\n", logBanKey(key), logError(err))
+	}
+
+	return &storedBans{score: score, denied: denied}
+}
+
+// lookupBanScore returns the ban score of the ban key that key returns,
+// or false if it returns false. key is called while holding the mutex,
+// and the ban store is read after releasing it.
+func (t *Tracker) lookupBanScore(key func() (string, bool)) (uint32, bool) {
+	t.mutex.RLock()
+
+	k, ok := key()
+	store := t.banStore
+	score := t.banScore(k)
+
+	t.mutex.RUnlock()
+
+	if !ok {
+		return 0, false
+	}
+
+	if store == nil {
+		return score, true
+	}
+
+	score, err := store.BanScore(k)
+	if err != nil {
+		logBan.Errorf("Unable to get ban score for %s: %s\n", logBanKey(k), logError(err))
+	}
+
+	return score, true
+}
+
+// banScore returns the ban score of a ban key kept in the tracker.
+// This method assumes the caller is holding the mutex.
+func (t *Tracker) banScore(key string) uint32 {
+	if data := t.banData[key]; data != nil {
+		return data.score
+	}
+
+	return 0
+}
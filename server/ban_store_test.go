@@ -0,0 +1,150 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/ulule/limiter/v3"
+	sredis "github.com/ulule/limiter/v3/drivers/store/redis"
+)
+
+func newTestRedis(t *testing.T) redis.UniversalClient {
+	s := miniredis.RunT(t)
+
+	client := redis.NewClient(&redis.Options{Addr: s.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	return client
+}
+
+func TestRedisBanStoreSharesBans(t *testing.T) {
+	client := newTestRedis(t)
+
+	first := NewTracker(5, 0, 0, 0, 0)
+	first.SetBanStore(NewRedisBanStore(client, "test"))
+	second := NewTracker(5, 0, 0, 0, 0)
+	second.SetBanStore(NewRedisBanStore(client, "test"))
+
+	player := &PlayerData{conn: newIPConn("8.8.8.8"), verificationKey: "vk"}
+	for i := 0; i < maxBanScore; i++ {
+		first.increaseBanScore(player, false)
+	}
+
+	// the ban is not kept in the tracker
+	assert.Empty(t, first.banData)
+
+	stats := second.Stats("8.8.8.8", false)
+	assert.True(t, stats.Banned)
+	assert.Equal(t, uint32(maxBanScore), stats.BanScore)
+	assert.True(t, second.bannedByServer(newIPConn("8.8.8.8")))
+	assert.False(t, second.bannedByServer(newIPConn("8.8.4.4")))
+
+	// other prefixes do not see the ban
+	other := NewTracker(5, 0, 0, 0, 0)
+	other.SetBanStore(NewRedisBanStore(client, "other"))
+	assert.False(t, other.bannedByServer(newIPConn("8.8.8.8")))
+}
+
+func TestRedisBanStoreExpiresScores(t *testing.T) {
+	client := newTestRedis(t)
+	store := NewRedisBanStore(client, "test")
+
+	require.NoError(t, store.IncreaseBanScore("8.8.8.8"))
+
+	// an increase that has already expired is not counted
+	require.NoError(t, client.ZAdd(context.Background(), store.banKey("8.8.8.8"), redis.Z{
+		Score:  float64(time.Now().Add(-time.Second).UnixNano()),
+		Member: "expired",
+	}).Err())
+
+	score, err := store.BanScore("8.8.8.8")
+	require.NoError(t, err)
+	assert.Equal(t, uint32(banScoreTick), score)
+
+	score, err = store.BanScore("8.8.4.4")
+	require.NoError(t, err)
+	assert.Equal(t, uint32(0), score)
+}
+
+func TestRedisBanStoreBans(t *testing.T) {
+	client := newTestRedis(t)
+	store := NewRedisBanStore(client, "test")
+
+	require.NoError(t, store.IncreaseBanScore("8.8.8.8"))
+	require.NoError(t, store.DenyMatch("8.8.8.8", "8.8.4.4"))
+
+	score, denied, err := store.Bans("8.8.8.8", []string{"8.8.4.4", "1.1.1.1"})
+	require.NoError(t, err)
+	assert.Equal(t, uint32(banScoreTick), score)
+	assert.Equal(t, map[string]bool{"8.8.4.4": true}, denied)
+
+	score, denied, err = store.Bans("1.1.1.1", nil)
+	require.NoError(t, err)
+	assert.Equal(t, uint32(0), score)
+	assert.Empty(t, denied)
+}
+
+func TestRedisBanStoreSharesDenyMatches(t *testing.T) {
+	client := newTestRedis(t)
+
+	first := NewTracker(5, 0, 0, 0, 0)
+	first.SetBanStore(NewRedisBanStore(client, "test"))
+	second := NewTracker(5, 0, 0, 0, 0)
+	second.SetBanStore(NewRedisBanStore(client, "test"))
+
+	accused := &PlayerData{conn: newIPConn("8.8.8.8"), verificationKey: "accused"}
+	other := &PlayerData{conn: newIPConn("8.8.4.4"), verificationKey: "other"}
+	first.addDenyIPMatch(accused, &Pool{
+		frozenSnapshot: map[string]*PlayerData{
+			"accused": accused,
+			"other":   other,
+		},
+	}, false)
+
+	assert.Empty(t, first.denyIPMatch)
+
+	pool := &Pool{players: map[uint32]*PlayerData{1: other}}
+	second.pools[1] = pool
+
+	newcomer := &PlayerData{conn: newIPConn("1.1.1.1")}
+	assert.True(t, second.deniedByIPMatch(accused, pool, second.storedBans(accused)))
+	assert.False(t, second.deniedByIPMatch(newcomer, pool, second.storedBans(newcomer)))
+	assert.False(t, second.deniedByIPMatch(accused, &Pool{players: map[uint32]*PlayerData{}}, second.storedBans(accused)))
+}
+
+func TestRedisBanStoreFailsOpen(t *testing.T) {
+	s := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: s.Addr(), MaxRetries: -1})
+	defer client.Close()
+
+	tracker := NewTracker(5, 0, 0, 0, 0)
+	tracker.SetBanStore(NewRedisBanStore(client, "test"))
+	s.Close()
+
+	assert.False(t, tracker.bannedByServer(newIPConn("8.8.8.8")))
+}
+
+func TestRedisRateLimiterShared(t *testing.T) {
+	client := newTestRedis(t)
+	rate := limiter.Rate{Period: time.Minute, Limit: 2}
+
+	newLimiter := func() *RateLimiter {
+		store, err := sredis.NewStoreWithOptions(client, limiter.StoreOptions{Prefix: "test:limiter:shuffle"})
+		require.NoError(t, err)
+
+		return NewRateLimiter(store, rate)
+	}
+
+	first := newLimiter()
+	second := newLimiter()
+
+	assert.True(t, allowConnection(newIPConn("8.8.8.8"), first))
+	assert.True(t, allowConnection(newIPConn("8.8.8.8"), second))
+	assert.False(t, allowConnection(newIPConn("8.8.8.8"), first))
+	assert.True(t, allowConnection(newIPConn("8.8.4.4"), second))
+}
@@ -5,8 +5,6 @@ import (
 	"fmt"
 
 	"github.com/cashshuffle/cashshuffle/message"
-
-	log "github.com/sirupsen/logrus"
 )
 
 var validBlamereasons = []message.Reason{
@@ -50,7 +48,7 @@ func (pi *packetInfo) checkBlameMessage() error {
 
 	blamer := pi.tracker.playerByConnection(pi.conn)
 	if blamer == nil {
-		log.Debugf(logBlame+"Ignoring blame from %s because they have disconnected\n", getIP(pi.conn))
+		logBlame.Debugf("Ignoring blame from %s because they have disconnected\n", logIP(getIP(pi.conn)))
 		return nil
 	}
 	accusedKey := packet.GetMessage().GetBlame().GetAccused().GetKey()
@@ -62,26 +60,26 @@ func (pi *packetInfo) checkBlameMessage() error {
 	// After validating everything, we can skip the actual ban
 	// if the pool already has banned someone.
 	if blamer.pool.firstBan != nil {
-		log.Debugf(logBlame+"Ignoring blame in pool %d because a player is already banned\n", blamer.pool.num)
+		logBlame.Debugf("Ignoring blame in pool %d because a player is already banned\n", blamer.pool.num)
 		return nil
 	}
 
 	added := accused.addBlame(blamer.verificationKey)
 	if !added {
-		log.Debugf(logBlame+"Duplicate From: %s\n", blamer)
-		log.Debugf(logBlame+"Duplicate To: %s\n", accused)
+		logBlame.Debugf("Duplicate From: %s\n", blamer)
+		logBlame.Debugf("Duplicate To: %s\n", accused)
 		return nil
 	}
 
-	log.Debugf(logBlame+"Blame applied for reason: %s\n", reason)
-	log.Debugf(logBlame+"From: %s\n", blamer)
-	log.Debugf(logBlame+"To: %s\n", accused)
+	logBlame.Debugf("Blame applied for reason: %s\n", reason)
+	logBlame.Debugf("From: %s\n", blamer)
+	logBlame.Debugf("To: %s\n", accused)
 
 	if blamer.pool.IsBanned(accused) {
 		blamer.pool.firstBan = accused
-		pi.tracker.increaseBanScore(accused.conn, false)
-		log.Debugf(logBan+"User blamed out of round: %s\n", accused)
-		pi.tracker.addDenyIPMatch(accused.conn, accused.pool, false)
+		pi.tracker.increaseBanScore(accused, false)
+		logBan.Debugf("User blamed out of round: %s\n", accused)
+		pi.tracker.addDenyIPMatch(accused, accused.pool, false)
 	}
 
 	return nil
@@ -5,8 +5,6 @@ import (
 	"strings"
 
 	"github.com/cashshuffle/cashshuffle/message"
-
-	log "github.com/sirupsen/logrus"
 )
 
 var (
@@ -36,26 +34,26 @@ func (pi *packetInfo) broadcastMessage() {
 		} else {
 			sendingPlayer := pi.tracker.playerByConnection(pi.conn)
 			if sendingPlayer == nil {
-				log.Debug(logDirectMessage + "Sending message from disconnected player\n")
+				logDirectMessage.Debug("Sending message from disconnected player\n")
 			}
 
 			player := pi.tracker.playerByVerificationKey(strings.TrimLeft(vk, playerPrefix))
 			if player == nil {
-				log.Debugf(logDirectMessage+"Ignoring message to vk:%s because player has disconnected\n", vk)
+				logDirectMessage.Debugf("Ignoring message to vk:%s because player has disconnected\n", logKey(vk))
 				return
 			}
 
 			if player == sendingPlayer {
-				log.Debugf(logDirectMessage+"Ignoring message to self\nPlayer: %s\n", sendingPlayer)
+				logDirectMessage.Debugf("Ignoring message to self\nPlayer: %s\n", sendingPlayer)
 				return
 			}
 
-			log.Debugf(logDirectMessage+"From: %s\n", sendingPlayer)
-			log.Debugf(logDirectMessage+"To: %s\n", player)
+			logDirectMessage.Debugf("From: %s\n", sendingPlayer)
+			logDirectMessage.Debugf("To: %s\n", player)
 
 			// stop sending messages after the first error
-			if err := writeMessage(player.conn, msgs); err != nil {
-				log.Debugf(logDirectMessage+"Error writing message: %s\n", err)
+			if err := player.write(msgs); err != nil {
+				logDirectMessage.Debugf("Error writing message: %s\n", logError(err))
 				return
 			}
 		}
@@ -69,16 +67,22 @@ func (pi *packetInfo) broadcastAll(msgs []*message.Signed) {
 
 	sender := pi.tracker.connections[pi.conn]
 	if sender == nil {
-		log.Debugf(logBroadcast+"Ignoring message from %s because player has disconnected\n", getIP(pi.conn))
+		logBroadcast.Debugf("Ignoring message from %s because player has disconnected\n", logIP(getIP(pi.conn)))
 		return
 	}
 
-	log.Debugf(logBroadcast+"From: %s\n", sender)
+	logBroadcast.Debugf("From: %s\n", sender)
+
+	sender.pool.recordPhase(msgs)
+
+	if pi.tracker.resumeGracePeriod > 0 {
+		sender.pool.recordBroadcast(msgs)
+	}
 
 	for _, player := range sender.pool.players {
 		// Try to send the message to remaining players even if errors.
-		if err := writeMessage(player.conn, msgs); err != nil {
-			log.Debugf(logBroadcast+"Continuing to send after write error: %s\nTo: %s\n", err, player)
+		if err := player.write(msgs); err != nil {
+			logBroadcast.Debugf("Continuing to send after write error: %s\nTo: %s\n", logError(err), player)
 		}
 	}
 }
@@ -94,38 +98,57 @@ func (pi *packetInfo) announceStart() {
 	// If the user has disconnected, then no need to send
 	// the broadcast.
 	if sender == nil {
-		log.Debugf(logPhaseAnnounce+"Ignoring message from %s because player has disconnected\n", getIP(pi.conn))
+		logPhaseAnnounce.Debugf("Ignoring message from %s because player has disconnected\n", logIP(getIP(pi.conn)))
 		return
 	}
 
-	announcement := []*message.Signed{
-		{
-			Packet: &message.Packet{
-				Phase:  message.Phase_ANNOUNCEMENT,
-				Number: uint32(pi.tracker.poolSize),
-			},
-		},
-	}
-
 	for _, player := range sender.pool.players {
 		// The player now has an obligation to send verification key.
 		// Since we cannot differentiate between a user ignoring the message
 		// and an honest miss, we assume the user always receives the message.
 		player.isPassive = true
 
+		announcement, err := serverPacket(pi.tracker.identity, player.version, player.sessionID, &message.Packet{
+			Phase:  message.Phase_ANNOUNCEMENT,
+			Number: uint32(sender.pool.size),
+			Round:  sender.pool.Round(),
+		})
+		if err != nil {
+			logPhaseAnnounce.Warnf("Unable to sign announcement: %s\n", err)
+			continue
+		}
+
 		// Try to send the message to remaining players even if errors.
-		if err := writeMessage(player.conn, announcement); err != nil {
-			log.Debugf(logBroadcast+"Continuing to send after write error: %s\nTo: %s\n", err, player)
+		if err := player.write([]*message.Signed{announcement}); err != nil {
+			logBroadcast.Debugf("Continuing to send after write error: %s\nTo: %s\n", logError(err), player)
 		}
 	}
 }
 
+// broadcastJoinedPool tells everyone in the pool of p that p joined.
 func (pi *packetInfo) broadcastJoinedPool(p *PlayerData) {
-	m := message.Signed{
-		Packet: &message.Packet{
-			Number: p.number,
-		},
+	pi.tracker.mutex.RLock()
+	defer pi.tracker.mutex.RUnlock()
+
+	// If the user has disconnected, then no need to send
+	// the broadcast.
+	if pi.tracker.connections[pi.conn] == nil {
+		logBroadcast.Debugf("Ignoring message from %s because player has disconnected\n", logIP(getIP(pi.conn)))
+		return
 	}
 
-	pi.broadcastAll([]*message.Signed{&m})
+	for _, player := range p.pool.players {
+		m, err := serverPacket(pi.tracker.identity, player.version, player.sessionID, &message.Packet{
+			Number: p.number,
+		})
+		if err != nil {
+			logBroadcast.Warnf("Unable to sign joined pool message: %s\n", err)
+			continue
+		}
+
+		// Try to send the message to remaining players even if errors.
+		if err := player.write([]*message.Signed{m}); err != nil {
+			logBroadcast.Debugf("Continuing to send after write error: %s\nTo: %s\n", logError(err), player)
+		}
+	}
 }
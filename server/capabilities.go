@@ -0,0 +1,176 @@
+package server
+
+import (
+	"errors"
+	"net"
+	"time"
+
+	"github.com/cashshuffle/cashshuffle/message"
+)
+
+// errCapabilitiesSent is returned when a connection asks for the
+// capabilities of the server a second time.
+var errCapabilitiesSent = errors.New("capabilities already sent")
+
+// wantsCapabilities returns true if a client asks for the capabilities
+// of the server without registering.
+func (pi *packetInfo) wantsCapabilities() bool {
+	if len(pi.message.Packet) != 1 {
+		return false
+	}
+
+	p := pi.message.Packet[0].GetPacket()
+
+	return p.GetCapabilities() != nil && p.GetRegistration() == nil
+}
+
+// sendCapabilities replies to a client that asked for the capabilities
+// of the server before registering. The reply is bound to the nonce of
+// the request. The client still has to register
+// before the connect deadline, and may only ask once per connection.
+func (pi *packetInfo) sendCapabilities() error {
+	offered := pi.message.Packet[0].GetPacket().GetCapabilities()
+
+	if !pi.tracker.capabilitiesAsked(pi.conn, offered) {
+		if err := pi.registrationFailed(errCapabilitiesSent, highestVersion(offered.GetVersions())); err != nil {
+			return err
+		}
+
+		return errCapabilitiesSent
+	}
+
+	m, err := serverPacket(pi.tracker.serverIdentity(), highestVersion(offered.GetVersions()), pi.nonce(), &message.Packet{
+		Capabilities: pi.tracker.capabilities(pi.conn, offered),
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := writeMessage(pi.conn, []*message.Signed{m}); err != nil {
+		return err
+	}
+
+	// Writing the reply extended the deadline, so it is set back to
+	// what an unregistered connection gets.
+	if err := pi.conn.SetDeadline(time.Now().Add(connectDeadline)); err != nil {
+		logCommunication.Debugf("Unable to reset connect deadline: %s\n", logError(err))
+	}
+
+	return nil
+}
+
+// capabilitiesAsked records that a connection asked for the capabilities
+// of the server, offering its own. It returns false if the connection
+// already asked.
+func (t *Tracker) capabilitiesAsked(conn net.Conn, offered *message.Capabilities) bool {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	info := t.openConnections[conn]
+	if info == nil || info.capabilitiesSent {
+		return false
+	}
+
+	info.capabilitiesSent = true
+	info.resumable = hasFeature(offered, message.Feature_SESSION_RESUME)
+
+	return true
+}
+
+// capabilities returns what the server supports for a client on conn
+// that offered the capabilities in offered, which may be nil. The
+// versions are those the client offered that the server supports, or
+// every version the server supports if the client offered none. A server
+// that supports every version returns the versions the client offered.
+func (t *Tracker) capabilities(conn net.Conn, offered *message.Capabilities) *message.Capabilities {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+
+	c := &message.Capabilities{
+		Features: []message.Feature{message.Feature_ERROR_CODES, message.Feature_PHASE_TIMEOUTS},
+		PoolSize: uint32(t.poolSize),
+		Limits: &message.Limits{
+			MaxMessageLength: maxMessageLength,
+			ConnectTimeout:   uint32(connectDeadline / time.Second),
+			PhaseTimeout:     uint32(deadline / time.Second),
+		},
+	}
+
+	if t.identity != nil {
+		c.Features = append(c.Features, message.Feature_SERVER_SIGNATURES)
+		c.SignedPacketsVersion = SignedPacketsVersion
+	}
+
+	c.Versions = negotiateVersions(t.versions, offered.GetVersions())
+
+	if t.resumeGracePeriod > 0 {
+		c.Features = append(c.Features, message.Feature_SESSION_RESUME)
+		c.Limits.ResumeGracePeriod = uint32(t.resumeGracePeriod / time.Second)
+	}
+
+	// Per IP limits do not apply to Tor connections.
+	if info := t.openConnections[conn]; info == nil || !info.tor {
+		c.Limits.MaxConnectionsPerIp = uint32(t.limits.MaxConnectionsPerIP)
+		c.Limits.MaxPoolsPerIp = uint32(t.limits.MaxPoolsPerIP)
+	}
+
+	return c
+}
+
+// SetVersions sets the protocol versions the server supports, which it
+// tells clients and other servers. Every version is supported if there
+// are none.
+func (t *Tracker) SetVersions(versions []uint64) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.versions = versions
+}
+
+// negotiateVersions returns the offered versions that are supported. An
+// empty list of supported versions supports every version, and an empty
+// offer asks for every supported version.
+func negotiateVersions(supported []uint64, offered []uint64) []uint64 {
+	if len(supported) == 0 {
+		return offered
+	}
+
+	if len(offered) == 0 {
+		return supported
+	}
+
+	var versions []uint64
+	for _, s := range supported {
+		for _, o := range offered {
+			if s == o {
+				versions = append(versions, s)
+				break
+			}
+		}
+	}
+
+	return versions
+}
+
+// hasFeature returns true if c lists feature.
+func hasFeature(c *message.Capabilities, feature message.Feature) bool {
+	for _, f := range c.GetFeatures() {
+		if f == feature {
+			return true
+		}
+	}
+
+	return false
+}
+
+// highestVersion returns the highest of versions, or 0 if there are none.
+func highestVersion(versions []uint64) uint64 {
+	var highest uint64
+	for _, v := range versions {
+		if v > highest {
+			highest = v
+		}
+	}
+
+	return highest
+}
@@ -0,0 +1,145 @@
+package server
+
+import (
+	"crypto/ed25519"
+	"testing"
+
+	"github.com/cashshuffle/cashshuffle/message"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sendCapabilities asks the server for its capabilities.
+func (c *testClient) sendCapabilities(versions ...uint64) {
+	err := writeMessage(c.conn, []*message.Signed{{
+		Packet: &message.Packet{
+			Capabilities: &message.Capabilities{Versions: versions},
+		},
+	}})
+	require.NoError(c.h.t, err)
+}
+
+func TestCapabilities(t *testing.T) {
+	h := newTestHarness(t, basicPoolSize)
+	require.NoError(t, h.tracker.SetConnectionLimits(ConnectionLimits{MaxConnectionsPerIP: 4, MaxPoolsPerIP: 2}))
+
+	// without an identity every version is supported and nothing is signed
+	c := newTestClient(h)
+	c.Connect()
+	c.sendCapabilities(300, testVersion)
+
+	reply := c.popServerPacket()
+	assert.Nil(t, reply.GetSignature())
+	want := &message.Capabilities{
+		Versions: []uint64{300, testVersion},
+		Features: []message.Feature{message.Feature_ERROR_CODES, message.Feature_PHASE_TIMEOUTS},
+		PoolSize: basicPoolSize,
+		Limits: &message.Limits{
+			MaxMessageLength:    maxMessageLength,
+			MaxConnectionsPerIp: 4,
+			MaxPoolsPerIp:       2,
+			ConnectTimeout:      15,
+			PhaseTimeout:        180,
+		},
+	}
+	assert.True(t, proto.Equal(want, reply.GetPacket().GetCapabilities()), "%v", reply.GetPacket().GetCapabilities())
+
+	// the client can register after asking
+	c.sendRegistration(testVersion)
+	registered := c.popServerPacket()
+	assert.NotEmpty(t, registered.GetPacket().GetSession())
+	assert.Nil(t, registered.GetPacket().GetCapabilities())
+	c.popServerPacket()
+
+	// versions are supported without an identity too
+	h.tracker.SetVersions([]uint64{300, SignedPacketsVersion})
+
+	unsigned := newTestClient(h)
+	unsigned.Connect()
+	unsigned.sendCapabilities(300, 400)
+	assert.Equal(t, []uint64{300}, unsigned.popServerPacket().GetPacket().GetCapabilities().GetVersions())
+
+	key := newTestKey(t)
+	h.tracker.SetIdentity(NewIdentity(key, ServerAnnouncement{}, h.tracker))
+
+	signed := newTestClient(h)
+	signed.Connect()
+	signed.sendCapabilities(SignedPacketsVersion, 400)
+
+	reply = signed.popServerPacket()
+	assertSignedFor(t, key.Public().(ed25519.PublicKey), reply, nil)
+
+	caps := reply.GetPacket().GetCapabilities()
+	assert.Equal(t, []uint64{SignedPacketsVersion}, caps.GetVersions())
+	assert.Equal(t, []message.Feature{message.Feature_ERROR_CODES, message.Feature_PHASE_TIMEOUTS, message.Feature_SERVER_SIGNATURES}, caps.GetFeatures())
+	assert.Equal(t, uint64(SignedPacketsVersion), caps.GetSignedPacketsVersion())
+
+	// asking twice is refused
+	signed.sendCapabilities()
+	failed := signed.popServerPacket()
+	assert.Equal(t, "capabilities already sent", failed.GetPacket().GetMessage().GetStr())
+	assert.Equal(t, message.ErrorCode_CAPABILITIES_ALREADY_SENT, failed.GetPacket().GetMessage().GetErrorCode())
+	h.WaitNotConnected(signed)
+}
+
+func TestCapabilitiesAreBoundToNonce(t *testing.T) {
+	h := newTestHarness(t, basicPoolSize)
+
+	key := newTestKey(t)
+	h.tracker.SetIdentity(NewIdentity(key, ServerAnnouncement{}, h.tracker))
+	public := key.Public().(ed25519.PublicKey)
+
+	c := newTestClient(h)
+	c.Connect()
+
+	ask := func(nonce []byte) *message.Signed {
+		err := writeMessage(c.conn, []*message.Signed{{
+			Packet: &message.Packet{
+				Session:      nonce,
+				Capabilities: &message.Capabilities{Versions: []uint64{SignedPacketsVersion}},
+			},
+		}})
+		require.NoError(t, err)
+
+		return c.popServerPacket()
+	}
+
+	nonce := []byte("client nonce")
+	assertSignedFor(t, public, ask(nonce), nonce)
+
+	// so is the refusal to answer twice
+	failed := ask([]byte("another nonce"))
+	assert.Equal(t, "capabilities already sent", failed.GetPacket().GetMessage().GetStr())
+	assertSignedFor(t, public, failed, []byte("another nonce"))
+	h.WaitNotConnected(c)
+}
+
+func TestCapabilitiesWithRegistration(t *testing.T) {
+	h := newTestHarness(t, basicPoolSize)
+
+	c := newTestClient(h)
+	c.Connect()
+	c.version = testVersion
+
+	err := writeMessage(c.conn, []*message.Signed{{
+		Packet: &message.Packet{
+			FromKey:      &message.VerificationKey{Key: c.verificationKey},
+			Registration: &message.Registration{Amount: testAmount, Version: testVersion},
+			Capabilities: &message.Capabilities{},
+		},
+	}})
+	require.NoError(t, err)
+
+	registered := c.popServerPacket()
+	assert.NotEmpty(t, registered.GetPacket().GetSession())
+	assert.EqualValues(t, basicPoolSize, registered.GetPacket().GetCapabilities().GetPoolSize())
+}
+
+func TestNegotiateVersions(t *testing.T) {
+	assert.Equal(t, []uint64{1, 2}, negotiateVersions(nil, []uint64{1, 2}))
+	assert.Equal(t, []uint64{1, 2}, negotiateVersions([]uint64{1, 2}, nil))
+	assert.Equal(t, []uint64{2}, negotiateVersions([]uint64{1, 2}, []uint64{2, 3}))
+	assert.Empty(t, negotiateVersions([]uint64{1}, []uint64{3}))
+}
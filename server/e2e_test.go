@@ -217,6 +217,49 @@ func TestPassivePlayersGetBanEffects(t *testing.T) {
 	h.WaitEmptyInboxes(allClients)
 }
 
+// TestPoolLimitRejectsRegistration confirms that a client over the pool
+// limit for its IP is told why registration failed.
+func TestPoolLimitRejectsRegistration(t *testing.T) {
+	h := newTestHarness(t, basicPoolSize)
+	if err := h.tracker.SetConnectionLimits(ConnectionLimits{MaxPoolsPerIP: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	client := newTestClient(h)
+	client.Connect()
+	client.Register(testAmount, testVersion, []*testClient{client}, false, true)
+
+	// all test clients share an IP, so a second pool is over the limit
+	other := newTestClient(h)
+	other.Connect()
+	registration := &message.Signed{
+		Packet: &message.Packet{
+			FromKey: &message.VerificationKey{
+				Key: other.verificationKey,
+			},
+			Registration: &message.Registration{
+				Amount:  testAmount + 1,
+				Version: testVersion,
+			},
+		},
+	}
+	if err := writeMessage(other.conn, []*message.Signed{registration}); err != nil {
+		t.Fatal(err)
+	}
+
+	response, err := other.inbox.PopOldest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := response.message.GetPacket()[0].GetPacket().GetMessage()
+	assert.Equal(t, message.Reason_INVALIDFORMAT, msg.GetBlame().GetReason())
+	assert.Equal(t, errMaxPoolsPerIP.Error(), msg.GetStr())
+	assert.Equal(t, message.ErrorCode_LIMIT_REACHED, msg.GetErrorCode())
+
+	h.WaitNotConnected(other)
+	h.WaitEmptyInboxes([]*testClient{client})
+}
+
 // testHarness holds the pieces required for automating a shuffle.
 type testHarness struct {
 	tracker *Tracker
@@ -303,7 +346,7 @@ func (c *testClient) Connect() {
 	c.inbox = newTestInbox(c.conn)
 
 	// handle the server side of the connection
-	go handleConnection(c.remoteConn, c.h.packets, c.h.tracker)
+	go handleConnection(c.remoteConn, c.h.packets, c.h.tracker, false)
 }
 
 // Disconnect simulates the client dropping the connection and confirms that
@@ -641,14 +684,14 @@ type testP2PBan struct {
 // AssertP2PBans confirms that the provided p2p bans exist on the server.
 // This must be called after ban messages have been received.
 func (h *testHarness) AssertP2PBans(bans []testP2PBan) {
+	h.tracker.mutex.RLock()
 	expectedPairs := make([]ipPair, 0)
 	for _, ban := range bans {
-		ipA := getIP(ban.a.remoteConn)
-		ipB := getIP(ban.b.remoteConn)
+		ipA := h.tracker.ipKey(getIP(ban.a.remoteConn))
+		ipB := h.tracker.ipKey(getIP(ban.b.remoteConn))
 		expectedPairs = append(expectedPairs, newIPPair(ipA, ipB))
 	}
 
-	h.tracker.mutex.RLock()
 	actualPairs := make([]ipPair, 0)
 	for p := range h.tracker.denyIPMatch {
 		actualPairs = append(actualPairs, p)
@@ -667,13 +710,13 @@ type testServerBanData struct {
 // AssertServerBans confirms the status of server bans.
 // This must be called after ban messages have been received.
 func (h *testHarness) AssertServerBans(cbs []testServerBanData) {
+	h.tracker.mutex.RLock()
 	expectedBanData := make(map[string]banData)
 	for _, bd := range cbs {
-		ip := getIP(bd.client.remoteConn)
+		ip := h.tracker.ipKey(getIP(bd.client.remoteConn))
 		expectedBanData[ip] = bd.banData
 	}
 
-	h.tracker.mutex.RLock()
 	actualBanData := make(map[string]banData)
 	for ip, bd := range h.tracker.banData {
 		actualBanData[ip] = *bd
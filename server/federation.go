@@ -0,0 +1,687 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	// announcementContext is the signing context of server announcements.
+	announcementContext = "cashshuffle/announcement/v1"
+
+	// serverListContext is the signing context of /servers lists.
+	serverListContext = "cashshuffle/servers/v1"
+
+	// FederationExpiry is how long a server stays listed after its last
+	// announcement. Servers must announce more often than this.
+	FederationExpiry = time.Hour
+
+	// federationClockSkew is how far the time of an announcement may be
+	// from the time it is received.
+	federationClockSkew = 5 * time.Minute
+
+	// federationTimeout bounds every request between servers.
+	federationTimeout = 10 * time.Second
+
+	// federationStartPoll is how often federation checks whether the
+	// listeners are bound before it first announces the server.
+	federationStartPoll = 100 * time.Millisecond
+
+	// maxAnnouncementSize is the largest announcement that is read.
+	maxAnnouncementSize = 16 << 10
+
+	// maxFederatedServers is the most servers a federation lists.
+	maxFederatedServers = 1000
+)
+
+var (
+	errStaleAnnouncement  = errors.New("announcement is stale")
+	errUntrustedServer    = errors.New("server is not trusted")
+	errTooManyServers     = errors.New("too many servers")
+	errNoAnnouncementHost = errors.New("announcement has no host or onion address")
+	errOwnAnnouncement    = errors.New("announcement is from this server")
+	errIdentityMismatch   = errors.New("host serves another identity")
+	errPrivateAddress     = errors.New("address is not public")
+)
+
+// ServerAnnouncement describes how clients reach a server. Ports that are
+// not served are left out.
+type ServerAnnouncement struct {
+	Host                    string   `json:"host,omitempty"`
+	OnionAddress            string   `json:"onionAddress,omitempty"`
+	TLS                     bool     `json:"tls"`
+	ShufflePort             int      `json:"shufflePort,omitempty"`
+	ShuffleWebSocketPort    int      `json:"shuffleWebSocketPort,omitempty"`
+	StatsPort               int      `json:"statsPort,omitempty"`
+	HTTPPort                int      `json:"httpPort,omitempty"`
+	WebSocketPath           string   `json:"webSocketPath,omitempty"`
+	TorShufflePort          int      `json:"torShufflePort,omitempty"`
+	TorShuffleWebSocketPort int      `json:"torShuffleWebSocketPort,omitempty"`
+	TorStatsPort            int      `json:"torStatsPort,omitempty"`
+	TorHTTPPort             int      `json:"torHTTPPort,omitempty"`
+	PoolSizes               []int    `json:"poolSizes"`
+	Versions                []uint64 `json:"versions,omitempty"`
+	ServerVersion           string   `json:"serverVersion,omitempty"`
+	AnnouncedAt             int64    `json:"announcedAt"`
+}
+
+// validate returns an error unless clients can reach the announced
+// server. Servers only reachable over Tor announce no host, and can not
+// be probed.
+func (a *ServerAnnouncement) validate() error {
+	if a.OnionAddress != "" && !strings.HasSuffix(a.OnionAddress, ".onion") {
+		return fmt.Errorf("invalid onion address: %s", a.OnionAddress)
+	}
+
+	if a.Host == "" {
+		if a.OnionAddress == "" {
+			return errNoAnnouncementHost
+		}
+
+		return nil
+	}
+
+	_, err := a.statsURL()
+	return err
+}
+
+// probed returns true if the announced server can be probed.
+func (a *ServerAnnouncement) probed() bool {
+	return a.Host != ""
+}
+
+// statsURL returns where the stats of the announced server are served.
+func (a *ServerAnnouncement) statsURL() (string, error) {
+	return a.url("/stats")
+}
+
+// identityURL returns where the identity of the announced server is
+// served.
+func (a *ServerAnnouncement) identityURL() (string, error) {
+	return a.url("/identity")
+}
+
+// url returns the URL of path on the stats port of the announced server.
+func (a *ServerAnnouncement) url(path string) (string, error) {
+	if a.Host == "" {
+		return "", errNoAnnouncementHost
+	}
+
+	port := a.StatsPort
+	if port == 0 {
+		port = a.HTTPPort
+	}
+
+	if port <= 0 || port > 65535 {
+		return "", fmt.Errorf("announcement has no stats port: %d", port)
+	}
+
+	scheme := "http"
+	if a.TLS {
+		scheme = "https"
+	}
+
+	return fmt.Sprintf("%s://%s%s", scheme, net.JoinHostPort(a.Host, strconv.Itoa(port)), path), nil
+}
+
+// sameStats returns true if a and b serve their stats at the same URL.
+func (a *ServerAnnouncement) sameStats(b *ServerAnnouncement) bool {
+	aURL, _ := a.statsURL()
+	bURL, _ := b.statsURL()
+	return aURL == bURL
+}
+
+// FederatedServer is a server listed on /servers. Announcement is the
+// server's own signed announcement, so clients can check it themselves.
+type FederatedServer struct {
+	Announcement *SignedMessage `json:"announcement"`
+	Healthy      bool           `json:"healthy"`
+	LastSeen     int64          `json:"lastSeen"`
+	LastProbe    int64          `json:"lastProbe,omitempty"`
+	LatencyMs    int64          `json:"latencyMs,omitempty"`
+
+	// Unprobed is true for servers only reachable over Tor, which are
+	// listed without being probed.
+	Unprobed bool `json:"unprobed,omitempty"`
+}
+
+// ServerList is the signed payload of /servers.
+type ServerList struct {
+	Self        *SignedMessage    `json:"self"`
+	Servers     []FederatedServer `json:"servers"`
+	GeneratedAt int64             `json:"generatedAt"`
+}
+
+// FederationOptions configures how a server takes part in federation.
+// The server announces itself with Identity. Only announcements signed
+// with TrustedKeys are accepted, so a server without any only announces
+// itself.
+type FederationOptions struct {
+	Identity    *Identity
+	Peers       []string
+	Interval    time.Duration
+	TrustedKeys []string
+}
+
+// federatedServer is what a federation knows about another server.
+type federatedServer struct {
+	announcement *SignedMessage
+	info         ServerAnnouncement
+	lastSeen     time.Time
+	lastProbe    time.Time
+	healthy      bool
+	latency      time.Duration
+
+	// verified is true once the announced host served the identity of
+	// the server. Servers are only listed once verified.
+	verified bool
+}
+
+// Federation announces the server to its peers, keeps the servers that
+// announce themselves and probes their stats to track their health.
+type Federation struct {
+	opts    FederationOptions
+	trusted map[string]bool
+	client  *http.Client
+
+	// probeClient requests announced hosts. It only connects to public
+	// addresses, so announcements can not point probes at the network
+	// of the server.
+	probeClient *http.Client
+
+	mutex   sync.RWMutex
+	servers map[string]*federatedServer
+}
+
+// federation is the federation served on /servers, if enabled.
+var federation struct {
+	mutex sync.RWMutex
+	f     *Federation
+}
+
+// NewFederation creates a federation for the server.
+func NewFederation(opts FederationOptions) (*Federation, error) {
+	if opts.Interval <= 0 || opts.Interval >= FederationExpiry {
+		return nil, fmt.Errorf("invalid federation interval: %s", opts.Interval)
+	}
+
+	current := opts.Identity.current()
+	if err := current.validate(); err != nil {
+		return nil, err
+	}
+
+	trusted := make(map[string]bool)
+	for _, k := range opts.TrustedKeys {
+		key, err := decodePublicKey(k)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted key: %s", k)
+		}
+
+		trusted[encodePublicKey(key)] = true
+	}
+
+	return &Federation{
+		opts:        opts,
+		trusted:     trusted,
+		client:      &http.Client{Timeout: federationTimeout},
+		probeClient: newProbeClient(),
+		servers:     make(map[string]*federatedServer),
+	}, nil
+}
+
+// newProbeClient creates a client that refuses to connect to loopback,
+// private, link-local and other non-public addresses. The address is
+// checked after it is resolved, so hosts can not rebind to one.
+func newProbeClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout: federationTimeout,
+		Control: func(network string, address string, c syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+
+			if !publicIP(net.ParseIP(host)) {
+				return errPrivateAddress
+			}
+
+			return nil
+		},
+	}
+
+	return &http.Client{
+		Timeout: federationTimeout,
+		// proxies are not used, since the address check would only
+		// see the proxy
+		Transport: &http.Transport{
+			Proxy:               nil,
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: federationTimeout,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// publicIP returns true if ip is a public unicast address.
+func publicIP(ip net.IP) bool {
+	return ip != nil && !ip.IsLoopback() && !ip.IsPrivate() && !ip.IsUnspecified() &&
+		!ip.IsLinkLocalUnicast() && !ip.IsLinkLocalMulticast() &&
+		!ip.IsInterfaceLocalMulticast() && !ip.IsMulticast()
+}
+
+// EnableFederation serves f on /servers and starts announcing the server
+// to its peers until ctx is done.
+func EnableFederation(ctx context.Context, f *Federation, h *Health) {
+	federation.mutex.Lock()
+	federation.f = f
+	federation.mutex.Unlock()
+
+	go f.run(ctx, h)
+}
+
+// currentFederation returns the enabled federation, if any.
+func currentFederation() *Federation {
+	federation.mutex.RLock()
+	defer federation.mutex.RUnlock()
+
+	return federation.f
+}
+
+// run announces the server and probes the other servers every interval.
+// The first announcement waits for the listeners to be bound, so that
+// peers are not told about a server they can not reach yet.
+func (f *Federation) run(ctx context.Context, h *Health) {
+	for len(h.live()) > 0 {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(federationStartPoll):
+		}
+	}
+
+	ticker := time.NewTicker(f.opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		f.announceToPeers(ctx)
+		f.probe(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// announceToPeers sends the announcement of the server to every peer.
+func (f *Federation) announceToPeers(ctx context.Context) {
+	sm, err := f.opts.Identity.announce()
+	if err != nil {
+		logFederation.Warnf("Unable to sign announcement: %s\n", err)
+		return
+	}
+
+	body, err := json.Marshal(sm)
+	if err != nil {
+		logFederation.Warnf("Unable to encode announcement: %s\n", err)
+		return
+	}
+
+	for _, peer := range f.opts.Peers {
+		if err := f.announceTo(ctx, peer, body); err != nil {
+			logFederation.Warnf("Unable to announce to %s: %s\n", peer, err)
+			continue
+		}
+
+		logFederation.Debugf("Announced to %s\n", peer)
+	}
+}
+
+// announceTo sends an announcement to a peer.
+func (f *Federation) announceTo(ctx context.Context, peer string, body []byte) error {
+	url := strings.TrimSuffix(peer, "/") + "/servers/announce"
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+
+	return nil
+}
+
+// accept stores a signed announcement from another server.
+func (f *Federation) accept(sm *SignedMessage) error {
+	key, err := sm.verify(announcementContext)
+	if err != nil {
+		return err
+	}
+
+	id := encodePublicKey(key)
+	if f.opts.Identity.owns(key) {
+		return errOwnAnnouncement
+	}
+
+	if !f.trusted[id] {
+		return errUntrustedServer
+	}
+
+	var a ServerAnnouncement
+	if err := json.Unmarshal(sm.Payload, &a); err != nil {
+		return err
+	}
+
+	if err := a.validate(); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	announced := time.Unix(a.AnnouncedAt, 0)
+	if announced.Before(now.Add(-federationClockSkew)) || announced.After(now.Add(federationClockSkew)) {
+		return errStaleAnnouncement
+	}
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	s, ok := f.servers[id]
+	if !ok {
+		f.expire(now)
+		if len(f.servers) >= maxFederatedServers {
+			return errTooManyServers
+		}
+
+		s = &federatedServer{}
+		f.servers[id] = s
+	} else if a.AnnouncedAt <= s.info.AnnouncedAt {
+		// replayed or reordered announcements are ignored
+		return errStaleAnnouncement
+	}
+
+	// a server that moves has to be verified again
+	if !s.info.sameStats(&a) {
+		s.verified = false
+		s.healthy = false
+	}
+
+	s.announcement = sm
+	s.info = a
+	s.lastSeen = now
+
+	return nil
+}
+
+// expire forgets servers that stopped announcing themselves.
+// This method assumes the caller is holding the mutex.
+func (f *Federation) expire(now time.Time) {
+	for id, s := range f.servers {
+		if now.Sub(s.lastSeen) > FederationExpiry {
+			delete(f.servers, id)
+		}
+	}
+}
+
+// probe checks that every known server is served by its announced host
+// and requests its stats to record whether it is healthy. Servers that
+// are draining are not healthy.
+func (f *Federation) probe(ctx context.Context) {
+	f.mutex.Lock()
+	f.expire(time.Now())
+	targets := make(map[string]ServerAnnouncement, len(f.servers))
+	for id, s := range f.servers {
+		if s.info.probed() {
+			targets[id] = s.info
+		}
+	}
+	f.mutex.Unlock()
+
+	var wg sync.WaitGroup
+	for id, a := range targets {
+		wg.Add(1)
+		go func(id string, a ServerAnnouncement) {
+			defer wg.Done()
+
+			start := time.Now()
+			verified, err := f.probeServer(ctx, id, &a)
+			latency := time.Since(start)
+
+			if err != nil {
+				logFederation.Debugf("Probe of %s failed: %s\n", a.Host, err)
+			}
+
+			f.mutex.Lock()
+			defer f.mutex.Unlock()
+
+			// the server may have moved while it was probed
+			if s, ok := f.servers[id]; ok && s.info.sameStats(&a) {
+				// unreachable servers stay verified, they are just unhealthy
+				s.lastProbe = start
+				s.verified = verified || s.verified && err != errIdentityMismatch
+				s.healthy = err == nil
+				s.latency = latency
+			}
+		}(id, a)
+	}
+	wg.Wait()
+}
+
+// probeServer checks that the host of a serves the identity id and
+// then probes its stats. It returns whether the identity was verified.
+func (f *Federation) probeServer(ctx context.Context, id string, a *ServerAnnouncement) (bool, error) {
+	url, err := a.identityURL()
+	if err != nil {
+		return false, err
+	}
+
+	if err := f.probeIdentity(ctx, url, id); err != nil {
+		return false, err
+	}
+
+	url, err = a.statsURL()
+	if err != nil {
+		return true, err
+	}
+
+	return true, f.probeStats(ctx, url)
+}
+
+// probeIdentity returns an error unless url serves the identity id.
+func (f *Federation) probeIdentity(ctx context.Context, url string, id string) error {
+	resp, err := f.get(ctx, url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var sm SignedMessage
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxAnnouncementSize)).Decode(&sm); err != nil {
+		return errIdentityMismatch
+	}
+
+	key, err := sm.verify(announcementContext)
+	if err != nil || encodePublicKey(key) != id {
+		return errIdentityMismatch
+	}
+
+	return nil
+}
+
+// probeStats returns an error unless url serves the stats of a server
+// that is accepting players.
+func (f *Federation) probeStats(ctx context.Context, url string) error {
+	resp, err := f.get(ctx, url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var stats TrackerStats
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&stats); err != nil {
+		return err
+	}
+
+	if stats.Draining {
+		return errDraining
+	}
+
+	return nil
+}
+
+// get requests url of an announced server with the probe client. It
+// returns an error unless the response is 200 OK.
+func (f *Federation) get(ctx context.Context, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := f.probeClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, errors.New(resp.Status)
+	}
+
+	return resp, nil
+}
+
+// list signs the list of verified and unprobed servers, ordered by host
+// and onion address.
+func (f *Federation) list() (*SignedMessage, error) {
+	self, err := f.opts.Identity.announce()
+	if err != nil {
+		return nil, err
+	}
+
+	f.mutex.Lock()
+	f.expire(time.Now())
+
+	servers := make([]*federatedServer, 0, len(f.servers))
+	for _, s := range f.servers {
+		if s.verified || !s.info.probed() {
+			servers = append(servers, s)
+		}
+	}
+
+	sort.Slice(servers, func(i, j int) bool {
+		a, b := servers[i].info, servers[j].info
+		if a.Host != b.Host {
+			return a.Host < b.Host
+		}
+		return a.OnionAddress < b.OnionAddress
+	})
+
+	l := ServerList{
+		Self:        self,
+		Servers:     make([]FederatedServer, 0, len(servers)),
+		GeneratedAt: time.Now().Unix(),
+	}
+
+	for _, s := range servers {
+		fs := FederatedServer{
+			Announcement: s.announcement,
+			Healthy:      s.healthy,
+			LastSeen:     s.lastSeen.Unix(),
+			Unprobed:     !s.info.probed(),
+		}
+
+		if !s.lastProbe.IsZero() {
+			fs.LastProbe = s.lastProbe.Unix()
+			fs.LatencyMs = int64(s.latency / time.Millisecond)
+		}
+
+		l.Servers = append(l.Servers, fs)
+	}
+	f.mutex.Unlock()
+
+	return f.opts.Identity.sign(serverListContext, l)
+}
+
+// handleFederation registers the federation endpoints on mux. They
+// answer 404 unless federation is enabled.
+func handleFederation(mux *http.ServeMux, limit *RateLimiter, trustedProxies TrustedProxies) {
+	mux.Handle("/servers", limit.handler(http.HandlerFunc(serverList), trustedProxies.clientIP))
+	mux.Handle("/servers/announce", limit.handler(http.HandlerFunc(serverAnnounce), trustedProxies.clientIP))
+}
+
+// serverList serves the signed list of servers.
+func serverList(w http.ResponseWriter, r *http.Request) {
+	f := currentFederation()
+	if f == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	sm, err := f.list()
+	if err != nil {
+		logFederation.Warnf("Unable to sign server list: %s\n", err)
+		http.Error(w, "unable to sign server list", http.StatusInternalServerError)
+		return
+	}
+
+	b, _ := json.Marshal(sm)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Write(b)
+}
+
+// serverAnnounce accepts an announcement from another server.
+func serverAnnounce(w http.ResponseWriter, r *http.Request) {
+	f := currentFederation()
+	if f == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	if r.Method != "POST" {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var sm SignedMessage
+	if err := json.NewDecoder(io.LimitReader(r.Body, maxAnnouncementSize)).Decode(&sm); err != nil {
+		http.Error(w, "invalid announcement", http.StatusBadRequest)
+		return
+	}
+
+	switch err := f.accept(&sm); err {
+	case nil:
+		w.WriteHeader(http.StatusNoContent)
+	case errUntrustedServer:
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errTooManyServers:
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+	default:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,334 @@
+package server
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/ulule/limiter/v3"
+	"github.com/ulule/limiter/v3/drivers/store/memory"
+)
+
+// newTestKey returns a new signing key.
+func newTestKey(t *testing.T) ed25519.PrivateKey {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	return key
+}
+
+// setFederation serves f on /servers for the rest of the test.
+func setFederation(t *testing.T, f *Federation) {
+	federation.mutex.Lock()
+	previous := federation.f
+	federation.f = f
+	federation.mutex.Unlock()
+
+	t.Cleanup(func() {
+		federation.mutex.Lock()
+		federation.f = previous
+		federation.mutex.Unlock()
+	})
+}
+
+// startFederatedServer serves the stats and federation endpoints of a
+// tracker and returns the server and a federation announcing a.
+func startFederatedServer(t *testing.T, a ServerAnnouncement, opts FederationOptions) (*httptest.Server, *Federation) {
+	tracker := NewTracker(basicPoolSize, 1337, 1338, 0, 0)
+
+	mux := http.NewServeMux()
+	limit := NewRateLimiter(memory.NewStore(), limiter.Rate{Period: time.Minute, Limit: 100})
+	handleStats(mux, tracker, false, limit, nil)
+	handleFederation(mux, limit, nil)
+
+	s := httptest.NewServer(mux)
+	t.Cleanup(s.Close)
+
+	host, port, err := net.SplitHostPort(s.Listener.Addr().String())
+	require.NoError(t, err)
+
+	a.Host = host
+	a.StatsPort, err = strconv.Atoi(port)
+	require.NoError(t, err)
+
+	opts.Identity = NewIdentity(newTestKey(t), a, tracker)
+	tracker.SetIdentity(opts.Identity)
+
+	if opts.Interval == 0 {
+		opts.Interval = time.Minute
+	}
+
+	f, err := NewFederation(opts)
+	require.NoError(t, err)
+
+	// test servers listen on loopback, which announced hosts may not use
+	f.probeClient = f.client
+
+	return s, f
+}
+
+// trust makes d accept the announcements of f.
+func trust(d *Federation, f *Federation) {
+	d.trusted[f.opts.Identity.PublicKey()] = true
+}
+
+func TestSignedMessage(t *testing.T) {
+	key := newTestKey(t)
+
+	sm, err := signMessage(key, announcementContext, ServerAnnouncement{Host: "<shuffle>"})
+	require.NoError(t, err)
+
+	signer, err := sm.verify(announcementContext)
+	require.NoError(t, err)
+	assert.True(t, signer.Equal(key.Public()))
+
+	// a signature is only valid for its context
+	_, err = sm.verify(serverListContext)
+	assert.Equal(t, errBadSignature, err)
+
+	// messages survive being passed on
+	b, err := json.Marshal(sm)
+	require.NoError(t, err)
+
+	var passed SignedMessage
+	require.NoError(t, json.Unmarshal(b, &passed))
+	_, err = passed.verify(announcementContext)
+	assert.NoError(t, err)
+
+	tampered := *sm
+	tampered.Payload = json.RawMessage(`{"host":"evil"}`)
+	_, err = tampered.verify(announcementContext)
+	assert.Equal(t, errBadSignature, err)
+
+	spaced := *sm
+	spaced.Payload = append(json.RawMessage(" "), sm.Payload...)
+	_, err = spaced.verify(announcementContext)
+	assert.Equal(t, errBadPayload, err)
+}
+
+func TestLoadSigningKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys", "signing.key")
+
+	key, err := LoadSigningKey(path)
+	require.NoError(t, err)
+
+	again, err := LoadSigningKey(path)
+	require.NoError(t, err)
+	assert.True(t, key.Equal(again))
+}
+
+func TestFederationAnnounceAndProbe(t *testing.T) {
+	directory, d := startFederatedServer(t, ServerAnnouncement{}, FederationOptions{})
+	setFederation(t, d)
+
+	announced, f := startFederatedServer(t, ServerAnnouncement{ShufflePort: 1337}, FederationOptions{
+		Peers: []string{directory.URL},
+	})
+	f.opts.Identity.si.(*Tracker).SetVersions([]uint64{testVersion})
+	trust(d, f)
+
+	ctx := context.Background()
+	f.announceToPeers(ctx)
+
+	// servers are listed once their host serves their identity
+	unverified, err := d.list()
+	require.NoError(t, err)
+
+	var list ServerList
+	require.NoError(t, json.Unmarshal(unverified.Payload, &list))
+	assert.Empty(t, list.Servers)
+
+	d.probe(ctx)
+
+	resp, err := http.Get(directory.URL + "/servers")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var sm SignedMessage
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&sm))
+
+	signer, err := sm.verify(serverListContext)
+	require.NoError(t, err)
+	assert.Equal(t, d.opts.Identity.PublicKey(), encodePublicKey(signer))
+
+	require.NoError(t, json.Unmarshal(sm.Payload, &list))
+	require.Len(t, list.Servers, 1)
+
+	listed := list.Servers[0]
+	assert.True(t, listed.Healthy)
+	assert.NotZero(t, listed.LastProbe)
+
+	// the listed announcement is signed by the announcing server
+	signer, err = listed.Announcement.verify(announcementContext)
+	require.NoError(t, err)
+	assert.Equal(t, f.opts.Identity.PublicKey(), encodePublicKey(signer))
+
+	var a ServerAnnouncement
+	require.NoError(t, json.Unmarshal(listed.Announcement.Payload, &a))
+	assert.Equal(t, []int{basicPoolSize}, a.PoolSizes)
+	assert.Equal(t, []uint64{testVersion}, a.Versions)
+	assert.Equal(t, 1337, a.ShufflePort)
+
+	// servers whose stats can not be fetched are not healthy
+	announced.Close()
+	d.probe(ctx)
+
+	updated, err := d.list()
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(updated.Payload, &list))
+	assert.False(t, list.Servers[0].Healthy)
+}
+
+func TestFederationRejectsAnnouncements(t *testing.T) {
+	trustedKey := newTestKey(t)
+	_, d := startFederatedServer(t, ServerAnnouncement{}, FederationOptions{
+		TrustedKeys: []string{encodePublicKey(trustedKey.Public().(ed25519.PublicKey))},
+	})
+
+	announce := func(key ed25519.PrivateKey, a ServerAnnouncement) error {
+		sm, err := signMessage(key, announcementContext, a)
+		require.NoError(t, err)
+		return d.accept(sm)
+	}
+
+	now := time.Now().Unix()
+	a := ServerAnnouncement{Host: "shuffle.example.com", StatsPort: 8080, AnnouncedAt: now}
+
+	assert.Equal(t, errUntrustedServer, announce(newTestKey(t), a))
+	assert.Equal(t, errOwnAnnouncement, announce(d.opts.Identity.key, a))
+	assert.NoError(t, announce(trustedKey, a))
+
+	// replays are refused
+	assert.Equal(t, errStaleAnnouncement, announce(trustedKey, a))
+
+	old := a
+	old.AnnouncedAt = now - int64(time.Hour/time.Second)
+	assert.Equal(t, errStaleAnnouncement, announce(trustedKey, old))
+
+	noHost := a
+	noHost.Host = ""
+	noHost.AnnouncedAt = now + 1
+	assert.Equal(t, errNoAnnouncementHost, announce(trustedKey, noHost))
+
+	badOnion := noHost
+	badOnion.OnionAddress = "shuffle.example.com"
+	assert.Error(t, announce(trustedKey, badOnion))
+
+	// without trusted keys no announcement is accepted
+	_, untrusting := startFederatedServer(t, ServerAnnouncement{}, FederationOptions{})
+	sm, err := signMessage(trustedKey, announcementContext, a)
+	require.NoError(t, err)
+	assert.Equal(t, errUntrustedServer, untrusting.accept(sm))
+}
+
+func TestFederationVerifiesIdentity(t *testing.T) {
+	directory, d := startFederatedServer(t, ServerAnnouncement{}, FederationOptions{})
+	setFederation(t, d)
+
+	other, _ := startFederatedServer(t, ServerAnnouncement{}, FederationOptions{})
+	_, f := startFederatedServer(t, ServerAnnouncement{}, FederationOptions{
+		Peers: []string{directory.URL},
+	})
+	trust(d, f)
+
+	// the announcement claims the host of another server
+	host, port, err := net.SplitHostPort(other.Listener.Addr().String())
+	require.NoError(t, err)
+
+	a := f.opts.Identity.announcement
+	a.Host = host
+	a.StatsPort, err = strconv.Atoi(port)
+	require.NoError(t, err)
+	a.AnnouncedAt = time.Now().Unix()
+
+	sm, err := signMessage(f.opts.Identity.key, announcementContext, a)
+	require.NoError(t, err)
+	require.NoError(t, d.accept(sm))
+
+	ctx := context.Background()
+	d.probe(ctx)
+
+	list, err := d.list()
+	require.NoError(t, err)
+
+	var l ServerList
+	require.NoError(t, json.Unmarshal(list.Payload, &l))
+	assert.Empty(t, l.Servers)
+
+	id := f.opts.Identity.PublicKey()
+	url, err := a.identityURL()
+	require.NoError(t, err)
+	assert.Equal(t, errIdentityMismatch, d.probeIdentity(ctx, url, id))
+}
+
+func TestFederationListsOnionServers(t *testing.T) {
+	_, d := startFederatedServer(t, ServerAnnouncement{}, FederationOptions{})
+
+	key := newTestKey(t)
+	d.trusted[encodePublicKey(key.Public().(ed25519.PublicKey))] = true
+
+	// servers only reachable over Tor announce no host
+	sm, err := signMessage(key, announcementContext, ServerAnnouncement{
+		OnionAddress:   "example.onion",
+		TorStatsPort:   8080,
+		TorShufflePort: 1339,
+		AnnouncedAt:    time.Now().Unix(),
+	})
+	require.NoError(t, err)
+	require.NoError(t, d.accept(sm))
+
+	// they are listed without being probed
+	d.probe(context.Background())
+
+	list, err := d.list()
+	require.NoError(t, err)
+
+	var l ServerList
+	require.NoError(t, json.Unmarshal(list.Payload, &l))
+	require.Len(t, l.Servers, 1)
+	assert.True(t, l.Servers[0].Unprobed)
+	assert.False(t, l.Servers[0].Healthy)
+	assert.Zero(t, l.Servers[0].LastProbe)
+}
+
+func TestProbeClientRefusesPrivateAddresses(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer s.Close()
+
+	// a proxy would be the only address checked
+	client := newProbeClient()
+	assert.Nil(t, client.Transport.(*http.Transport).Proxy)
+
+	_, err := client.Get(s.URL)
+	require.Error(t, err)
+	assert.ErrorIs(t, err, errPrivateAddress)
+
+	for _, ip := range []string{"127.0.0.1", "10.0.0.1", "192.168.1.1", "169.254.169.254", "::1", "fe80::1", "fd00::1", "0.0.0.0"} {
+		assert.False(t, publicIP(net.ParseIP(ip)), ip)
+	}
+	assert.True(t, publicIP(net.ParseIP("203.0.113.1")))
+}
+
+func TestFederationDisabled(t *testing.T) {
+	setFederation(t, nil)
+
+	mux := http.NewServeMux()
+	handleFederation(mux, NewRateLimiter(memory.NewStore(), limiter.Rate{Period: time.Minute, Limit: 10}), nil)
+
+	for _, path := range []string{"/servers", "/servers/announce"} {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest("POST", path, nil))
+		assert.Equal(t, http.StatusNotFound, w.Code, path)
+	}
+}
@@ -0,0 +1,202 @@
+package server
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/acme/autocert"
+)
+
+var errDraining = errors.New("server is draining")
+
+// Health tracks whether the server is up and can take new players. It
+// has its own lock so probes do not wait on the tracker.
+type Health struct {
+	mutex    sync.RWMutex
+	expected int
+	bound    int
+	draining bool
+	certDays int
+	autocert *autocert.Manager
+	host     string
+}
+
+// NewHealth creates the health state of a server.
+func NewHealth() *Health {
+	return &Health{}
+}
+
+// healthStatus is the body of the health endpoints.
+type healthStatus struct {
+	Status  string   `json:"status"`
+	Reasons []string `json:"reasons,omitempty"`
+}
+
+// ExpectListeners sets the number of listeners the server starts. The
+// server is not healthy until all of them are bound.
+func (h *Health) ExpectListeners(n int) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.expected = n
+}
+
+// SetDraining turns drain mode on or off. New players are refused while
+// draining, and pools that already started can finish.
+func (h *Health) SetDraining(draining bool) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.draining = draining
+}
+
+// SetReadyCertDays sets how many days TLS certificates must still be
+// valid for the server to be ready.
+func (h *Health) SetReadyCertDays(days int) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.certDays = days
+}
+
+// SetAutocert checks the certificate m has for host when the server is
+// asked if it is ready.
+func (h *Health) SetAutocert(m *autocert.Manager, host string) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.autocert = m
+	h.host = host
+}
+
+// listenerBound records that a listener is bound.
+func (h *Health) listenerBound() {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.bound++
+}
+
+// isDraining returns true in drain mode.
+func (h *Health) isDraining() bool {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+
+	return h.draining
+}
+
+// live returns the reasons the server is not live.
+func (h *Health) live() []string {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+
+	if h.bound < h.expected {
+		return []string{fmt.Sprintf("%d of %d listeners bound", h.bound, h.expected)}
+	}
+
+	return nil
+}
+
+// ready returns the reasons the server is not ready for new players.
+func (h *Health) ready() []string {
+	reasons := h.live()
+
+	h.mutex.RLock()
+	draining := h.draining
+	certDays := h.certDays
+	m := h.autocert
+	host := h.host
+	h.mutex.RUnlock()
+
+	if draining {
+		reasons = append(reasons, errDraining.Error())
+	}
+
+	expiry, ok := certificates.expiry()
+	if m != nil {
+		autocertExpiry, cached, err := autocertExpiry(m, host)
+		if err != nil {
+			return append(reasons, fmt.Sprintf("certificate unavailable: %s", err))
+		}
+
+		if cached && (!ok || autocertExpiry.Before(expiry)) {
+			expiry, ok = autocertExpiry, true
+		}
+	}
+
+	if ok && time.Now().AddDate(0, 0, certDays).After(expiry) {
+		reasons = append(reasons, fmt.Sprintf("certificate expires %s", expiry.UTC().Format(time.RFC3339)))
+	}
+
+	return reasons
+}
+
+// autocertExpiry returns when the ECDSA certificate m keeps for host
+// expires. Only the cache is read, so probes never start an ACME order.
+// False is returned if no certificate has been issued yet, since the
+// first handshake does that.
+func autocertExpiry(m *autocert.Manager, host string) (time.Time, bool, error) {
+	if m.Cache == nil {
+		return time.Time{}, false, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	data, err := m.Cache.Get(ctx, host)
+	if err == autocert.ErrCacheMiss {
+		return time.Time{}, false, nil
+	}
+	if err != nil {
+		return time.Time{}, false, err
+	}
+
+	// the cache holds the private key followed by the chain
+	for block, rest := pem.Decode(data); block != nil; block, rest = pem.Decode(rest) {
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+
+		leaf, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return time.Time{}, false, err
+		}
+
+		return leaf.NotAfter, true, nil
+	}
+
+	return time.Time{}, false, errors.New("no certificate in cache")
+}
+
+// handleHealth registers the health endpoints on mux. They are not rate
+// limited so that probes are never refused.
+func handleHealth(mux *http.ServeMux, h *Health) {
+	mux.HandleFunc("/healthz", healthJSON(h.live))
+	mux.HandleFunc("/readyz", healthJSON(h.ready))
+}
+
+// healthJSON returns a handler reporting the reasons check returns.
+// The status is 503 if there are any.
+func healthJSON(check func() []string) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		status := healthStatus{Status: "ok"}
+		code := http.StatusOK
+
+		if reasons := check(); len(reasons) > 0 {
+			status = healthStatus{Status: "unavailable", Reasons: reasons}
+			code = http.StatusServiceUnavailable
+		}
+
+		b, _ := json.Marshal(status)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		w.Write(b)
+	}
+}
@@ -0,0 +1,178 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/ulule/limiter/v3"
+	"github.com/ulule/limiter/v3/drivers/store/memory"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// getHealth requests a health endpoint and returns its status code and body.
+func getHealth(t *testing.T, mux *http.ServeMux, path string) (int, healthStatus) {
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+
+	var status healthStatus
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &status))
+
+	return w.Code, status
+}
+
+func TestHealthWaitsForListeners(t *testing.T) {
+	h := NewHealth()
+
+	mux := http.NewServeMux()
+	handleHealth(mux, h)
+
+	h.ExpectListeners(2)
+	listener, err := listen("127.0.0.1", 0, nil, h)
+	require.NoError(t, err)
+	defer listener.Close()
+
+	code, status := getHealth(t, mux, "/healthz")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, []string{"1 of 2 listeners bound"}, status.Reasons)
+
+	listener, err = listen("127.0.0.1", 0, nil, h)
+	require.NoError(t, err)
+	defer listener.Close()
+
+	code, status = getHealth(t, mux, "/healthz")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "ok", status.Status)
+}
+
+func TestReadyWhileNotDraining(t *testing.T) {
+	h := NewHealth()
+
+	tracker := NewTracker(basicPoolSize, 1337, 1338, 0, 0)
+	tracker.SetHealth(h)
+	limit := NewRateLimiter(memory.NewStore(), limiter.Rate{Period: time.Minute, Limit: 1})
+
+	mux := http.NewServeMux()
+	handleStats(mux, tracker, false, limit, nil)
+	handleHealth(mux, h)
+
+	// probes do not count against the stats rate limit
+	for i := 0; i < 3; i++ {
+		code, _ := getHealth(t, mux, "/readyz")
+		assert.Equal(t, http.StatusOK, code)
+	}
+
+	h.SetDraining(true)
+
+	code, status := getHealth(t, mux, "/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, []string{errDraining.Error()}, status.Reasons)
+	assert.True(t, tracker.Stats("", false).Draining)
+
+	// draining is not a liveness failure
+	code, _ = getHealth(t, mux, "/healthz")
+	assert.Equal(t, http.StatusOK, code)
+
+	err := tracker.add(&PlayerData{
+		conn:            newIPConn("8.8.8.8"),
+		verificationKey: "02a1b2c3d4e5f6",
+		amount:          testAmount,
+		version:         testVersion,
+	})
+	assert.Equal(t, errDraining, err)
+
+	h.SetDraining(false)
+	code, _ = getHealth(t, mux, "/readyz")
+	assert.Equal(t, http.StatusOK, code)
+}
+
+func TestReadyChecksCertificateExpiry(t *testing.T) {
+	h := NewHealth()
+
+	dir := t.TempDir()
+	cert := filepath.Join(dir, "server.crt")
+	key := filepath.Join(dir, "server.key")
+	writeTestCertificate(t, cert, key, "expiring")
+
+	_, err := createTLSConfig(cert, key, nil)
+	require.NoError(t, err)
+	defer func() {
+		certificates.mutex.Lock()
+		delete(certificates.pairs, [2]string{cert, key})
+		certificates.mutex.Unlock()
+	}()
+
+	mux := http.NewServeMux()
+	handleHealth(mux, h)
+
+	code, _ := getHealth(t, mux, "/readyz")
+	assert.Equal(t, http.StatusOK, code)
+
+	// the test certificate expires in an hour
+	h.SetReadyCertDays(1)
+
+	code, status := getHealth(t, mux, "/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	require.Len(t, status.Reasons, 1)
+	assert.Contains(t, status.Reasons[0], "certificate expires")
+}
+
+func TestReadyChecksAutocertExpiry(t *testing.T) {
+	h := NewHealth()
+
+	dir := t.TempDir()
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		DNSNames:     []string{"shuffle.example.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().AddDate(0, 0, 10),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &priv.PublicKey, priv)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(priv)
+	require.NoError(t, err)
+
+	// the cache holds the key followed by the certificate
+	cached := append(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})...)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "shuffle.example.com"), cached, 0600))
+
+	m := &autocert.Manager{Cache: autocert.DirCache(dir), Prompt: autocert.AcceptTOS, RenewBefore: 2 * time.Hour}
+
+	mux := http.NewServeMux()
+	handleHealth(mux, h)
+
+	h.SetAutocert(m, "shuffle.example.com")
+	h.SetReadyCertDays(1)
+	code, _ := getHealth(t, mux, "/readyz")
+	assert.Equal(t, http.StatusOK, code)
+
+	h.SetReadyCertDays(30)
+	code, status := getHealth(t, mux, "/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	require.Len(t, status.Reasons, 1)
+	assert.Contains(t, status.Reasons[0], "certificate expires")
+
+	// hosts without a certificate yet are not ordered one by probes
+	h.SetAutocert(m, "other.example.com")
+	code, _ = getHealth(t, mux, "/readyz")
+	assert.Equal(t, http.StatusOK, code)
+
+	_, err = os.Stat(filepath.Join(dir, "acme_account+key"))
+	assert.True(t, os.IsNotExist(err))
+}
@@ -0,0 +1,81 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// DefaultWebsocketPath is the path websocket connections are accepted on
+// by the HTTP server.
+const DefaultWebsocketPath = "/ws"
+
+// StartHTTPServer brings up an HTTP server that serves websocket
+// connections on webSocketPath and stats on /stats, so both can be
+// reached through one port. Websocket connections and stats requests
+// are rate limited by webSocketLimit and statsLimit. PROXY protocol
+// headers are read from proxyProtocol sources and forwarded headers are
+// only honored for requests from trustedProxies.
+func StartHTTPServer(ip string, port int, cert string, key string, t *Tracker, h *Health, m *autocert.Manager, tor bool, webSocketLimit *RateLimiter, statsLimit *RateLimiter, proxyProtocol []string, trustedProxies TrustedProxies, webSocketPath string, opts WebsocketOptions) error {
+	mux, err := newHTTPMux(t, h, tor, webSocketLimit, statsLimit, trustedProxies, webSocketPath, opts)
+	if err != nil {
+		return err
+	}
+
+	srv := newWebsocketServer(fmt.Sprintf("%s:%d", ip, port), mux)
+
+	torStr := ""
+	if tor {
+		torStr = "Tor"
+	}
+
+	listener, err := listen(ip, port, proxyProtocol, h)
+	if err != nil {
+		return err
+	}
+
+	logListener.Infof("%sHTTP Listening on TCP %s:%d (websocket path: %s, tls: %v)\n", torStr, ip, port, webSocketPath, tlsEnabled(cert, key, m))
+
+	return serveHTTP(srv, listener, cert, key, m)
+}
+
+// newHTTPMux creates the handler of the HTTP server.
+func newHTTPMux(t *Tracker, h *Health, tor bool, webSocketLimit *RateLimiter, statsLimit *RateLimiter, trustedProxies TrustedProxies, webSocketPath string, opts WebsocketOptions) (*http.ServeMux, error) {
+	mux := http.NewServeMux()
+	handleAPI(mux, t, h, tor, statsLimit, trustedProxies)
+
+	if err := checkWebsocketPath(mux, webSocketPath); err != nil {
+		return nil, err
+	}
+
+	mux.Handle(webSocketPath, webSocketLimit.handler(websocketHandler(t, tor, trustedProxies, opts), trustedProxies.clientIP))
+
+	return mux, nil
+}
+
+// ValidateWebsocketPath returns an error if websocket connections can not
+// be served on path.
+func ValidateWebsocketPath(path string) error {
+	mux := http.NewServeMux()
+	handleAPI(mux, &Tracker{}, NewHealth(), false, &RateLimiter{}, nil)
+
+	return checkWebsocketPath(mux, path)
+}
+
+// checkWebsocketPath returns an error if path is not absolute, is under
+// the versioned API, or is already served by mux.
+func checkWebsocketPath(mux *http.ServeMux, path string) error {
+	if !strings.HasPrefix(path, "/") || strings.HasPrefix(path, "/v1/") {
+		return fmt.Errorf("invalid websocket path: %s", path)
+	}
+
+	r := &http.Request{Method: http.MethodGet, URL: &url.URL{Path: path}}
+	if _, pattern := mux.Handler(r); pattern != "" {
+		return fmt.Errorf("invalid websocket path: %s is served by %s", path, pattern)
+	}
+
+	return nil
+}
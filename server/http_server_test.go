@@ -0,0 +1,70 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/ulule/limiter/v3"
+	"github.com/ulule/limiter/v3/drivers/store/memory"
+)
+
+func TestHTTPServerSharesPort(t *testing.T) {
+	tracker := NewTracker(basicPoolSize, 1337, 1338, 0, 0)
+	webSocketLimit := NewRateLimiter(memory.NewStore(), limiter.Rate{Period: time.Minute, Limit: 10})
+	statsLimit := NewRateLimiter(memory.NewStore(), limiter.Rate{Period: time.Minute, Limit: 1})
+
+	mux, err := newHTTPMux(tracker, NewHealth(), false, webSocketLimit, statsLimit, nil, "/shuffle", WebsocketOptions{})
+	require.NoError(t, err)
+
+	s := httptest.NewServer(mux)
+	defer s.Close()
+
+	resp, err := http.Get(s.URL + "/stats")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+
+	// stats and websockets are limited separately
+	resp, err = http.Get(s.URL + "/stats")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+
+	ws, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(s.URL, "http")+"/shuffle", nil)
+	require.NoError(t, err)
+	ws.Close()
+
+	resp, err = http.Get(s.URL + "/")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestValidateWebsocketPath(t *testing.T) {
+	assert.NoError(t, ValidateWebsocketPath(DefaultWebsocketPath))
+	assert.NoError(t, ValidateWebsocketPath("/"))
+	assert.Error(t, ValidateWebsocketPath("ws"))
+	assert.Error(t, ValidateWebsocketPath("/stats"))
+	assert.Error(t, ValidateWebsocketPath("/v1/stats"))
+
+	// every registered endpoint is taken
+	for _, path := range []string{"/stats/stream", "/healthz", "/readyz", "/servers", "/servers/announce", "/identity"} {
+		assert.Error(t, ValidateWebsocketPath(path), path)
+	}
+	assert.NoError(t, ValidateWebsocketPath("/stats/ws"))
+}
+
+func TestHTTPMuxRejectsTakenPath(t *testing.T) {
+	tracker := NewTracker(basicPoolSize, 1337, 1338, 0, 0)
+	limit := NewRateLimiter(memory.NewStore(), limiter.Rate{Period: time.Minute, Limit: 10})
+
+	_, err := newHTTPMux(tracker, NewHealth(), false, limit, limit, nil, "/readyz", WebsocketOptions{})
+	assert.Error(t, err)
+}
@@ -0,0 +1,168 @@
+package server
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// statsContext is the signing context of stats responses.
+	statsContext = "cashshuffle/stats/v1"
+
+	// PublicKeyHeader names the key that signed a stats response.
+	PublicKeyHeader = "X-CashShuffle-Public-Key"
+
+	// SignatureHeader holds the signature of a stats response.
+	SignatureHeader = "X-CashShuffle-Signature"
+
+	// SignedAtHeader holds the unix time a stats response was signed.
+	SignedAtHeader = "X-CashShuffle-Signed-At"
+
+	// signedStatsHeaders lists the signature headers so that browsers
+	// let clients read them.
+	signedStatsHeaders = PublicKeyHeader + ", " + SignatureHeader + ", " + SignedAtHeader
+)
+
+// Identity is the long-term signing key of a server and what the server
+// tells clients about itself. Clients can pin the public key to know they
+// are talking to the same server, over Tor as well as TLS.
+type Identity struct {
+	key          ed25519.PrivateKey
+	announcement ServerAnnouncement
+	si           StatsInformer
+}
+
+// NewIdentity creates the identity of the server whose stats si reports.
+// The pool size, onion address and versions of the announcement are
+// filled in from the stats every time it is signed.
+func NewIdentity(key ed25519.PrivateKey, announcement ServerAnnouncement, si StatsInformer) *Identity {
+	return &Identity{
+		key:          key,
+		announcement: announcement,
+		si:           si,
+	}
+}
+
+// PublicKey returns the public key of the server as it appears in signed
+// messages.
+func (id *Identity) PublicKey() string {
+	return encodePublicKey(id.key.Public().(ed25519.PublicKey))
+}
+
+// owns returns true if key is the public key of the server.
+func (id *Identity) owns(key ed25519.PublicKey) bool {
+	return key.Equal(id.key.Public())
+}
+
+// sign encodes v and signs it for context.
+func (id *Identity) sign(context string, v interface{}) (*SignedMessage, error) {
+	return signMessage(id.key, context, v)
+}
+
+// current returns the announcement of the server as it is now.
+func (id *Identity) current() ServerAnnouncement {
+	a := id.announcement
+	stats := id.si.Stats("", false)
+
+	a.PoolSizes = []int{stats.PoolSize}
+	a.OnionAddress = stats.OnionAddress
+	a.Versions = stats.Versions
+	a.AnnouncedAt = time.Now().Unix()
+
+	return a
+}
+
+// announce signs the current announcement of the server.
+func (id *Identity) announce() (*SignedMessage, error) {
+	return id.sign(announcementContext, id.current())
+}
+
+// signStats sets the headers that sign a stats response body. The
+// signature covers the signing time, a newline and the body, so old
+// responses can not be passed off as new ones.
+func (id *Identity) signStats(h http.Header, body []byte) {
+	signedAt := strconv.FormatInt(time.Now().Unix(), 10)
+	payload := append([]byte(signedAt+"\n"), body...)
+
+	h.Set(PublicKeyHeader, id.PublicKey())
+	h.Set(SignedAtHeader, signedAt)
+	h.Set(SignatureHeader, base64.StdEncoding.EncodeToString(ed25519.Sign(id.key, signedBytes(statsContext, payload))))
+}
+
+// identityProvider returns the identity of the server, or nil if it has
+// none. The tracker implements it.
+type identityProvider interface {
+	serverIdentity() *Identity
+}
+
+// SetIdentity sets the identity stats and packets are signed with.
+func (t *Tracker) SetIdentity(id *Identity) {
+	t.mutex.Lock()
+	t.identity = id
+	t.mutex.Unlock()
+
+	logListener.Infof("Server identity: %s\n", id.PublicKey())
+}
+
+// DisableSigning runs the server without an identity, because err kept
+// it from loading one. Stats and packets are then not signed.
+func (t *Tracker) DisableSigning(err error) {
+	t.mutex.Lock()
+	t.identity = nil
+	t.mutex.Unlock()
+
+	logListener.Warnf("Stats and packets are not signed: %s\n", err)
+}
+
+// serverIdentity returns the identity of the server.
+func (t *Tracker) serverIdentity() *Identity {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+
+	return t.identity
+}
+
+// identityOf returns the identity of the server si reports on, if any.
+func identityOf(si StatsInformer) *Identity {
+	if ip, ok := si.(identityProvider); ok {
+		return ip.serverIdentity()
+	}
+
+	return nil
+}
+
+// writeStats writes a stats response, signed if the server has an
+// identity.
+func writeStats(w http.ResponseWriter, si StatsInformer, body []byte) {
+	if id := identityOf(si); id != nil {
+		id.signStats(w.Header(), body)
+	}
+
+	w.Write(body)
+}
+
+// serverIdentityJSON serves the signed announcement of the server.
+func serverIdentityJSON(si StatsInformer) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := identityOf(si)
+		if id == nil {
+			http.NotFound(w, r)
+			return
+		}
+
+		sm, err := id.announce()
+		if err != nil {
+			http.Error(w, "unable to sign identity", http.StatusInternalServerError)
+			return
+		}
+
+		b, _ := json.Marshal(sm)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Write(b)
+	}
+}
@@ -0,0 +1,98 @@
+package server
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/ulule/limiter/v3"
+	"github.com/ulule/limiter/v3/drivers/store/memory"
+)
+
+func TestSignedStats(t *testing.T) {
+	tracker := NewTracker(basicPoolSize, 1337, 1338, 0, 0)
+	tracker.SetOnionAddress("example.onion")
+
+	mux := http.NewServeMux()
+	handleStats(mux, tracker, true, NewRateLimiter(memory.NewStore(), limiter.Rate{Period: time.Minute, Limit: 10}), nil)
+
+	// without an identity nothing is signed
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", "/stats", nil))
+	assert.Empty(t, w.Header().Get(SignatureHeader))
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", "/identity", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	key := newTestKey(t)
+	tracker.SetIdentity(NewIdentity(key, ServerAnnouncement{ShufflePort: 1337, TorShufflePort: 1339}, tracker))
+	public := key.Public().(ed25519.PublicKey)
+
+	for _, path := range []string{"/stats", "/v1/stats"} {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		require.Equal(t, http.StatusOK, w.Code)
+
+		body, err := io.ReadAll(w.Body)
+		require.NoError(t, err)
+
+		h := w.Header()
+		assert.Equal(t, encodePublicKey(public), h.Get(PublicKeyHeader))
+		assert.Contains(t, h.Get("Access-Control-Expose-Headers"), SignatureHeader)
+
+		signedAt, err := strconv.ParseInt(h.Get(SignedAtHeader), 10, 64)
+		require.NoError(t, err)
+		assert.WithinDuration(t, time.Now(), time.Unix(signedAt, 0), 5*time.Second)
+
+		sig, err := base64.StdEncoding.DecodeString(h.Get(SignatureHeader))
+		require.NoError(t, err)
+
+		payload := append([]byte(h.Get(SignedAtHeader)+"\n"), body...)
+		assert.True(t, ed25519.Verify(public, signedBytes(statsContext, payload), sig), path)
+
+		// the signature does not cover other times
+		payload = append([]byte(strconv.FormatInt(signedAt-1, 10)+"\n"), body...)
+		assert.False(t, ed25519.Verify(public, signedBytes(statsContext, payload), sig), path)
+	}
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", "/identity", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var sm SignedMessage
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &sm))
+
+	signer, err := sm.verify(announcementContext)
+	require.NoError(t, err)
+	assert.True(t, signer.Equal(public))
+
+	var a ServerAnnouncement
+	require.NoError(t, json.Unmarshal(sm.Payload, &a))
+	assert.Equal(t, "example.onion", a.OnionAddress)
+	assert.Equal(t, 1339, a.TorShufflePort)
+	assert.Equal(t, []int{basicPoolSize}, a.PoolSizes)
+}
+
+func TestDisableSigning(t *testing.T) {
+	b := captureLogs(t, LogOptions{Format: "json", Level: "info"})
+
+	tracker := NewTracker(basicPoolSize, 1337, 1338, 0, 0)
+	id := NewIdentity(newTestKey(t), ServerAnnouncement{}, tracker)
+	tracker.SetIdentity(id)
+	assert.Contains(t, b.String(), id.PublicKey())
+	assert.Contains(t, b.String(), `"bucket":"listener"`)
+
+	tracker.DisableSigning(errors.New("read-only file system"))
+	assert.Nil(t, tracker.serverIdentity())
+	assert.Contains(t, b.String(), "read-only file system")
+}
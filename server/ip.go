@@ -0,0 +1,59 @@
+package server
+
+import (
+	"fmt"
+	"net"
+)
+
+const (
+	// maxIPv4PrefixLength is the number of bits in an IPv4 address.
+	maxIPv4PrefixLength = 32
+
+	// maxIPv6PrefixLength is the number of bits in an IPv6 address.
+	maxIPv6PrefixLength = 128
+)
+
+// ipKey returns the key an IP is tracked under for bans and
+// deny matching. Addresses are truncated to the given prefix lengths
+// so that a client can not evade a ban by rotating through the
+// addresses it controls. IPv4-mapped IPv6 addresses are treated as
+// IPv4. A prefix length covering the whole address keeps the full
+// address. Prefix lengths are checked by validatePrefixLengths, which
+// rejects 0 since it would put every client under one key. Strings
+// that are not IP addresses are returned unchanged.
+func ipKey(ip string, ipv4PrefixLength int, ipv6PrefixLength int) string {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return ip
+	}
+
+	bits := maxIPv6PrefixLength
+	prefixLength := ipv6PrefixLength
+	if v4 := parsed.To4(); v4 != nil {
+		parsed = v4
+		bits = maxIPv4PrefixLength
+		prefixLength = ipv4PrefixLength
+	}
+
+	if prefixLength <= 0 || prefixLength >= bits {
+		return parsed.String()
+	}
+
+	mask := net.CIDRMask(prefixLength, bits)
+
+	return fmt.Sprintf("%s/%d", parsed.Mask(mask), prefixLength)
+}
+
+// validatePrefixLengths returns an error if the prefix lengths can
+// not be applied to IPv4 and IPv6 addresses.
+func validatePrefixLengths(ipv4PrefixLength int, ipv6PrefixLength int) error {
+	if ipv4PrefixLength < 1 || ipv4PrefixLength > maxIPv4PrefixLength {
+		return fmt.Errorf("invalid IPv4 prefix length: %d", ipv4PrefixLength)
+	}
+
+	if ipv6PrefixLength < 1 || ipv6PrefixLength > maxIPv6PrefixLength {
+		return fmt.Errorf("invalid IPv6 prefix length: %d", ipv6PrefixLength)
+	}
+
+	return nil
+}
@@ -0,0 +1,118 @@
+package server
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIPKey(t *testing.T) {
+	tests := []struct {
+		ip       string
+		v4Prefix int
+		v6Prefix int
+		expected string
+	}{
+		{"8.8.8.8", 32, 64, "8.8.8.8"},
+		{"8.8.8.8", 24, 64, "8.8.8.0/24"},
+		{"8.8.8.8", 0, 0, "8.8.8.8"},
+		{"::ffff:8.8.8.8", 32, 64, "8.8.8.8"},
+		{"::ffff:8.8.8.8", 24, 64, "8.8.8.0/24"},
+		{"2001:db8:1:2:3:4:5:6", 32, 64, "2001:db8:1:2::/64"},
+		{"2001:db8:1:2:3:4:5:6", 32, 48, "2001:db8:1::/48"},
+		{"2001:db8:1:2:3:4:5:6", 32, 128, "2001:db8:1:2:3:4:5:6"},
+		{"2001:db8:1:2:3:4:5:6", 32, 0, "2001:db8:1:2:3:4:5:6"},
+		{"", 32, 64, ""},
+		{"not-an-ip", 32, 64, "not-an-ip"},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.expected, ipKey(test.ip, test.v4Prefix, test.v6Prefix), test.ip)
+	}
+}
+
+func TestSetPrefixLengths(t *testing.T) {
+	tracker := NewTracker(5, 0, 0, 0, 0)
+
+	assert.NoError(t, tracker.SetPrefixLengths(24, 48))
+	assert.Equal(t, 24, tracker.ipv4PrefixLength)
+	assert.Equal(t, 48, tracker.ipv6PrefixLength)
+
+	assert.Error(t, tracker.SetPrefixLengths(0, 64))
+	assert.Error(t, tracker.SetPrefixLengths(33, 64))
+	assert.Error(t, tracker.SetPrefixLengths(32, 0))
+	assert.Error(t, tracker.SetPrefixLengths(32, 129))
+
+	// invalid values are not applied
+	assert.Equal(t, 24, tracker.ipv4PrefixLength)
+	assert.Equal(t, 48, tracker.ipv6PrefixLength)
+}
+
+func TestBanAppliesToPrefix(t *testing.T) {
+	tracker := NewTracker(5, 0, 0, 0, 0)
+
+	banned := newIPConn("2001:db8:1:2::1")
+	for i := 0; i < maxBanScore; i++ {
+		tracker.increaseBanScore(&PlayerData{conn: banned}, false)
+	}
+
+	assert.True(t, tracker.bannedByServer(banned))
+	assert.True(t, tracker.bannedByServer(newIPConn("2001:db8:1:2:ffff::1")))
+	assert.False(t, tracker.bannedByServer(newIPConn("2001:db8:1:3::1")))
+
+	stats := tracker.Stats("2001:db8:1:2::abcd", false)
+	assert.Equal(t, uint32(maxBanScore), stats.BanScore)
+	assert.True(t, stats.Banned)
+
+	mapped := newIPConn("::ffff:8.8.8.8")
+	for i := 0; i < maxBanScore; i++ {
+		tracker.increaseBanScore(&PlayerData{conn: mapped}, false)
+	}
+
+	assert.True(t, tracker.bannedByServer(newIPConn("8.8.8.8")))
+	assert.False(t, tracker.bannedByServer(newIPConn("8.8.8.9")))
+}
+
+func TestDenyIPMatchAppliesToPrefix(t *testing.T) {
+	tracker := NewTracker(5, 0, 0, 0, 0)
+	assert.NoError(t, tracker.SetPrefixLengths(24, 64))
+
+	accused := &PlayerData{conn: newIPConn("2001:db8:1:2::1")}
+	other := &PlayerData{conn: newIPConn("8.8.8.8")}
+
+	pool := &Pool{
+		players: map[uint32]*PlayerData{},
+		frozenSnapshot: map[string]*PlayerData{
+			"accused": accused,
+			"other":   other,
+		},
+	}
+	tracker.addDenyIPMatch(accused, pool, false)
+
+	assert.Len(t, tracker.denyIPMatch, 1)
+	assert.Contains(t, tracker.denyIPMatch, newIPPair("2001:db8:1:2::/64", "8.8.8.0/24"))
+
+	newPool := &Pool{
+		players: map[uint32]*PlayerData{
+			1: {conn: newIPConn("8.8.8.200")},
+		},
+	}
+
+	assert.True(t, tracker.deniedByIPMatch(&PlayerData{conn: newIPConn("2001:db8:1:2:aaaa::1")}, newPool, nil))
+	assert.False(t, tracker.deniedByIPMatch(&PlayerData{conn: newIPConn("2001:db8:1:3::1")}, newPool, nil))
+}
+
+// ipConn is a fake connection with a remote IP address.
+type ipConn struct {
+	fakeConn
+	addr net.Addr
+}
+
+func newIPConn(ip string) *ipConn {
+	return &ipConn{
+		addr: &net.TCPAddr{IP: net.ParseIP(ip), Port: 1337},
+	}
+}
+
+func (c *ipConn) RemoteAddr() net.Addr { return c.addr }
@@ -0,0 +1,143 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net"
+)
+
+var (
+	errMaxConnections          = errors.New("server connection limit reached")
+	errMaxConnectionsPerIP     = errors.New("connection limit reached for IP")
+	errMaxConnectionsPerPrefix = errors.New("connection limit reached for IP prefix")
+	errMaxPoolsPerIP           = errors.New("pool limit reached for IP")
+	errBanned                  = errors.New("banned by server")
+)
+
+// ConnectionLimits are the admission limits for connections.
+// A limit of 0 disables it. MaxPoolsPerIP groups IPs by the configured
+// prefix lengths. Per IP limits do not apply to Tor connections since
+// they all come from the same address.
+type ConnectionLimits struct {
+	MaxConnections          int
+	MaxConnectionsPerIP     int
+	MaxConnectionsPerPrefix int
+	MaxPoolsPerIP           int
+}
+
+// RejectionStats counts connections and registrations that were
+// refused by the connection limits.
+type RejectionStats struct {
+	MaxConnections          uint64 `json:"maxConnections"`
+	MaxConnectionsPerIP     uint64 `json:"maxConnectionsPerIP"`
+	MaxConnectionsPerPrefix uint64 `json:"maxConnectionsPerPrefix"`
+	MaxPoolsPerIP           uint64 `json:"maxPoolsPerIP"`
+}
+
+// connInfo is what the tracker knows about an open connection
+// before it registers.
+type connInfo struct {
+	ip               string
+	ipKey            string
+	tor              bool
+	capabilitiesSent bool
+	resumable        bool
+}
+
+// SetConnectionLimits sets the connection admission limits.
+func (t *Tracker) SetConnectionLimits(limits ConnectionLimits) error {
+	if limits.MaxConnections < 0 ||
+		limits.MaxConnectionsPerIP < 0 ||
+		limits.MaxConnectionsPerPrefix < 0 ||
+		limits.MaxPoolsPerIP < 0 {
+		return fmt.Errorf("invalid connection limits: %+v", limits)
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.limits = limits
+
+	return nil
+}
+
+// open starts tracking a new connection and returns an error if it
+// exceeds the connection limits.
+func (t *Tracker) open(conn net.Conn, tor bool) error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	ip := getIP(conn)
+	info := &connInfo{
+		ip:    ip,
+		ipKey: t.ipKey(ip),
+		tor:   tor,
+	}
+
+	if t.limits.MaxConnections > 0 && len(t.openConnections) >= t.limits.MaxConnections {
+		t.rejections.MaxConnections++
+		return errMaxConnections
+	}
+
+	if !tor {
+		if t.limits.MaxConnectionsPerIP > 0 && t.connectionsByIP[info.ip] >= t.limits.MaxConnectionsPerIP {
+			t.rejections.MaxConnectionsPerIP++
+			return errMaxConnectionsPerIP
+		}
+
+		if t.limits.MaxConnectionsPerPrefix > 0 && t.connectionsByIPKey[info.ipKey] >= t.limits.MaxConnectionsPerPrefix {
+			t.rejections.MaxConnectionsPerPrefix++
+			return errMaxConnectionsPerPrefix
+		}
+
+		t.connectionsByIP[info.ip]++
+		t.connectionsByIPKey[info.ipKey]++
+	}
+
+	t.openConnections[conn] = info
+
+	return nil
+}
+
+// close stops tracking a connection opened with open.
+func (t *Tracker) close(conn net.Conn) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	info := t.openConnections[conn]
+	if info == nil {
+		return
+	}
+
+	if !info.tor {
+		decrementCount(t.connectionsByIP, info.ip)
+		decrementCount(t.connectionsByIPKey, info.ipKey)
+	}
+
+	delete(t.openConnections, conn)
+}
+
+// decrementCount decrements a counter and removes it once it hits 0.
+func decrementCount(counts map[string]int, key string) {
+	counts[key]--
+	if counts[key] <= 0 {
+		delete(counts, key)
+	}
+}
+
+// poolsForIPKey returns the pools that have a player from the IP key.
+// Tor players are ignored.
+// This method assumes the caller is holding the mutex.
+func (t *Tracker) poolsForIPKey(key string) map[*Pool]bool {
+	pools := make(map[*Pool]bool)
+	for _, pool := range t.pools {
+		for _, p := range pool.players {
+			if !p.tor && t.ipKey(getIP(p.conn)) == key {
+				pools[pool] = true
+				break
+			}
+		}
+	}
+
+	return pools
+}
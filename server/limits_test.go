@@ -0,0 +1,103 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConnectionLimitsPerIP(t *testing.T) {
+	tracker := NewTracker(5, 0, 0, 0, 0)
+	require.NoError(t, tracker.SetPrefixLengths(24, 64))
+	require.NoError(t, tracker.SetConnectionLimits(ConnectionLimits{
+		MaxConnectionsPerIP:     2,
+		MaxConnectionsPerPrefix: 3,
+	}))
+
+	first := newIPConn("8.8.8.8")
+	assert.NoError(t, tracker.open(first, false))
+	assert.NoError(t, tracker.open(newIPConn("8.8.8.8"), false))
+	assert.Equal(t, errMaxConnectionsPerIP, tracker.open(newIPConn("8.8.8.8"), false))
+
+	assert.NoError(t, tracker.open(newIPConn("8.8.8.9"), false))
+	assert.Equal(t, errMaxConnectionsPerPrefix, tracker.open(newIPConn("8.8.8.10"), false))
+
+	// other prefixes are not affected
+	assert.NoError(t, tracker.open(newIPConn("8.8.4.4"), false))
+
+	// closing a connection frees up a slot
+	tracker.close(first)
+	assert.NoError(t, tracker.open(newIPConn("8.8.8.8"), false))
+
+	assert.Equal(t, RejectionStats{
+		MaxConnectionsPerIP:     1,
+		MaxConnectionsPerPrefix: 1,
+	}, tracker.Stats("", false).Rejections)
+}
+
+func TestConnectionLimitsTor(t *testing.T) {
+	tracker := NewTracker(5, 0, 0, 0, 0)
+	require.NoError(t, tracker.SetConnectionLimits(ConnectionLimits{
+		MaxConnections:      3,
+		MaxConnectionsPerIP: 1,
+	}))
+
+	// all tor connections share an IP, so only the global limit applies
+	assert.NoError(t, tracker.open(newIPConn("127.0.0.1"), true))
+	assert.NoError(t, tracker.open(newIPConn("127.0.0.1"), true))
+	assert.NoError(t, tracker.open(newIPConn("8.8.8.8"), false))
+	assert.Equal(t, errMaxConnections, tracker.open(newIPConn("127.0.0.1"), true))
+	assert.Equal(t, errMaxConnections, tracker.open(newIPConn("8.8.4.4"), false))
+
+	assert.Equal(t, uint64(2), tracker.Stats("", false).Rejections.MaxConnections)
+}
+
+func TestSetConnectionLimitsInvalid(t *testing.T) {
+	tracker := NewTracker(5, 0, 0, 0, 0)
+	assert.Error(t, tracker.SetConnectionLimits(ConnectionLimits{MaxPoolsPerIP: -1}))
+}
+
+func TestMaxPoolsPerIP(t *testing.T) {
+	tracker := NewTracker(5, 0, 0, 0, 0)
+	require.NoError(t, tracker.SetConnectionLimits(ConnectionLimits{MaxPoolsPerIP: 2}))
+
+	newPlayer := func(ip string, amount uint64, tor bool) *PlayerData {
+		conn := newIPConn(ip)
+		require.NoError(t, tracker.open(conn, tor))
+
+		return &PlayerData{
+			conn:            conn,
+			verificationKey: ip + string(rune(amount)),
+			amount:          amount,
+			blamedBy:        make(map[string]interface{}),
+		}
+	}
+
+	assert.NoError(t, tracker.add(newPlayer("8.8.8.8", 1, false)))
+	assert.NoError(t, tracker.add(newPlayer("8.8.8.8", 2, false)))
+	assert.Equal(t, errMaxPoolsPerIP, tracker.add(newPlayer("8.8.8.8", 3, false)))
+
+	// the IP can still join the pools it is already in
+	assert.NoError(t, tracker.add(newPlayer("8.8.8.8", 1, false)))
+
+	// other IPs and tor players are not affected
+	assert.NoError(t, tracker.add(newPlayer("8.8.4.4", 3, false)))
+	assert.NoError(t, tracker.add(newPlayer("127.0.0.1", 4, true)))
+	assert.NoError(t, tracker.add(newPlayer("127.0.0.1", 5, true)))
+	assert.NoError(t, tracker.add(newPlayer("127.0.0.1", 6, true)))
+
+	assert.Equal(t, uint64(1), tracker.Stats("", false).Rejections.MaxPoolsPerIP)
+	assert.Len(t, tracker.pools, 6)
+}
+
+func TestSetPoolSize(t *testing.T) {
+	tracker := NewTracker(5, 0, 0, 0, 0)
+
+	assert.NoError(t, tracker.SetPoolSize(3))
+	assert.Equal(t, 3, tracker.poolSize)
+
+	assert.Error(t, tracker.SetPoolSize(0))
+	assert.Error(t, tracker.SetPoolSize(-1))
+	assert.Equal(t, 3, tracker.poolSize)
+}
@@ -0,0 +1,336 @@
+package server
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// logBucket is a category of log messages. It is logged in the bucket
+// field and can be turned off.
+type logBucket string
+
+// log buckets
+const (
+	logPhaseAnnounce logBucket = "announce"
+	logBan           logBucket = "ban"
+	logBlame         logBucket = "blame"
+	logBroadcast     logBucket = "broadcast"
+	logCommunication logBucket = "communication"
+	logDirectMessage logBucket = "direct_message"
+	logFederation    logBucket = "federation"
+	logListener      logBucket = "listener"
+	logRound         logBucket = "round"
+)
+
+// logBuckets are all the log buckets.
+var logBuckets = []logBucket{
+	logPhaseAnnounce,
+	logBan,
+	logBlame,
+	logBroadcast,
+	logCommunication,
+	logDirectMessage,
+	logFederation,
+	logListener,
+	logRound,
+}
+
+const (
+	// privacyIPv4PrefixLength is the prefix IPv4 addresses are truncated to.
+	privacyIPv4PrefixLength = 24
+
+	// privacyIPv6PrefixLength is the prefix IPv6 addresses are truncated to.
+	privacyIPv6PrefixLength = 48
+
+	// privacyKeyLength is the number of characters kept of truncated
+	// verification keys and hashes.
+	privacyKeyLength = 8
+
+	// redacted replaces data hidden by the privacy mode.
+	redacted = "[redacted]"
+)
+
+// PrivacyMode controls how IPs and verification keys appear in logs.
+type PrivacyMode int
+
+const (
+	// PrivacyNone logs IPs and verification keys as they are.
+	PrivacyNone PrivacyMode = iota
+
+	// PrivacyHash logs a keyed hash of IPs and verification keys. The
+	// key is random for each run, so the same client can be followed
+	// through a run's logs but not looked up from an address.
+	PrivacyHash
+
+	// PrivacyTruncate logs IP prefixes and the start of verification keys.
+	PrivacyTruncate
+)
+
+// ParsePrivacyMode parses a privacy mode name: none, hash or truncate.
+func ParsePrivacyMode(s string) (PrivacyMode, error) {
+	switch s {
+	case "none":
+		return PrivacyNone, nil
+	case "hash":
+		return PrivacyHash, nil
+	case "truncate":
+		return PrivacyTruncate, nil
+	}
+
+	return PrivacyNone, fmt.Errorf("invalid privacy mode: %s", s)
+}
+
+// LogOptions configures logging.
+type LogOptions struct {
+	// Format is text or json.
+	Format string
+
+	// Level is a logrus level name such as info or debug.
+	Level string
+
+	// DisabledBuckets are the buckets that are not logged.
+	DisabledBuckets []string
+
+	// Privacy controls how IPs and verification keys are logged.
+	Privacy PrivacyMode
+
+	// NoLinkage keeps IPs and verification keys out of the same log
+	// line, so that logs can not link a client to its shuffles.
+	NoLinkage bool
+}
+
+// logging holds the logging options that apply to log buckets.
+var logging = struct {
+	mutex     sync.RWMutex
+	disabled  map[logBucket]bool
+	privacy   PrivacyMode
+	noLinkage bool
+	hashKey   []byte
+}{
+	disabled: make(map[logBucket]bool),
+}
+
+func init() {
+	log.SetFormatter(&log.TextFormatter{
+		ForceColors: true, // much more readable format for normal use
+	})
+
+	logging.hashKey = make([]byte, sha256.Size)
+	if _, err := rand.Read(logging.hashKey); err != nil {
+		panic(err)
+	}
+}
+
+// ConfigureLogging applies logging options. It can be called again while
+// the server is running.
+func ConfigureLogging(opts LogOptions) error {
+	var formatter log.Formatter
+	switch opts.Format {
+	case "text":
+		formatter = &log.TextFormatter{ForceColors: true}
+	case "json":
+		formatter = &log.JSONFormatter{}
+	default:
+		return fmt.Errorf("invalid log format: %s", opts.Format)
+	}
+
+	level, err := log.ParseLevel(opts.Level)
+	if err != nil {
+		return err
+	}
+
+	disabled := make(map[logBucket]bool)
+	for _, name := range opts.DisabledBuckets {
+		b, err := parseLogBucket(name)
+		if err != nil {
+			return err
+		}
+
+		disabled[b] = true
+	}
+
+	log.SetFormatter(formatter)
+	log.SetLevel(level)
+
+	logging.mutex.Lock()
+	defer logging.mutex.Unlock()
+
+	logging.disabled = disabled
+	logging.privacy = opts.Privacy
+	logging.noLinkage = opts.NoLinkage
+
+	return nil
+}
+
+// parseLogBucket returns the log bucket with a name.
+func parseLogBucket(name string) (logBucket, error) {
+	for _, b := range logBuckets {
+		if string(b) == name {
+			return b, nil
+		}
+	}
+
+	return "", fmt.Errorf("invalid log bucket: %s", name)
+}
+
+// log logs a message in the bucket unless it is disabled. The trailing
+// newline of the message is left out so it does not end up in JSON logs.
+func (b logBucket) log(level log.Level, msg string) {
+	logging.mutex.RLock()
+	disabled := logging.disabled[b]
+	logging.mutex.RUnlock()
+
+	if disabled || !log.IsLevelEnabled(level) {
+		return
+	}
+
+	log.WithField("bucket", string(b)).Log(level, strings.TrimSuffix(msg, "\n"))
+}
+
+// Debug logs a message at the debug level.
+func (b logBucket) Debug(args ...interface{}) {
+	b.log(log.DebugLevel, fmt.Sprint(args...))
+}
+
+// Debugf logs a message at the debug level.
+func (b logBucket) Debugf(format string, args ...interface{}) {
+	b.log(log.DebugLevel, fmt.Sprintf(format, args...))
+}
+
+// Infof logs a message at the info level.
+func (b logBucket) Infof(format string, args ...interface{}) {
+	b.log(log.InfoLevel, fmt.Sprintf(format, args...))
+}
+
+// Warn logs a message at the warn level.
+func (b logBucket) Warn(args ...interface{}) {
+	b.log(log.WarnLevel, fmt.Sprint(args...))
+}
+
+// Warnf logs a message at the warn level.
+func (b logBucket) Warnf(format string, args ...interface{}) {
+	b.log(log.WarnLevel, fmt.Sprintf(format, args...))
+}
+
+// Errorf logs a message at the error level.
+func (b logBucket) Errorf(format string, args ...interface{}) {
+	b.log(log.ErrorLevel, fmt.Sprintf(format, args...))
+}
+
+// privacyMode returns the current privacy mode.
+func privacyMode() PrivacyMode {
+	logging.mutex.RLock()
+	defer logging.mutex.RUnlock()
+
+	return logging.privacy
+}
+
+// noLinkage returns true if IPs and verification keys may not be logged
+// together.
+func noLinkage() bool {
+	logging.mutex.RLock()
+	defer logging.mutex.RUnlock()
+
+	return logging.noLinkage
+}
+
+// logIP returns an IP, or an IP prefix, as it should appear in logs.
+func logIP(ip string) string {
+	switch privacyMode() {
+	case PrivacyHash:
+		return privacyHash(ip)
+	case PrivacyTruncate:
+		return ipKey(strings.SplitN(ip, "/", 2)[0], privacyIPv4PrefixLength, privacyIPv6PrefixLength)
+	}
+
+	return ip
+}
+
+// logLinkedIP returns an IP that is logged along with a verification key
+// or a packet. It is left out in no-linkage mode.
+func logLinkedIP(ip string) string {
+	if noLinkage() {
+		return redacted
+	}
+
+	return logIP(ip)
+}
+
+// logKey returns a verification key as it should appear in logs.
+func logKey(vk string) string {
+	switch privacyMode() {
+	case PrivacyHash:
+		return privacyHash(vk)
+	case PrivacyTruncate:
+		if len(vk) > privacyKeyLength {
+			return vk[:privacyKeyLength] + "..."
+		}
+	}
+
+	return vk
+}
+
+// logBanKey returns a ban key as it should appear in logs. Ban keys of
+// Tor players can be verification keys, which are left out in no-linkage
+// mode since ban keys are logged next to IPs.
+func logBanKey(key string) string {
+	switch {
+	case strings.HasPrefix(key, torBanKeyPrefix):
+		if noLinkage() {
+			return torBanKeyPrefix + redacted
+		}
+
+		return torBanKeyPrefix + logKey(strings.TrimPrefix(key, torBanKeyPrefix))
+	case strings.HasPrefix(key, torCircuitBanKeyPrefix):
+		return key
+	}
+
+	return logIP(key)
+}
+
+// logData returns data that may contain IPs or verification keys, such
+// as packets, or a placeholder if the privacy mode hides them.
+func logData(data interface{}) interface{} {
+	if privacyMode() != PrivacyNone {
+		return redacted
+	}
+
+	return data
+}
+
+// logError returns an error as it should appear in logs. Network errors
+// name the addresses of both ends, so only the cause is kept when the
+// privacy mode hides IPs or in no-linkage mode.
+func logError(err error) error {
+	if privacyMode() == PrivacyNone && !noLinkage() {
+		return err
+	}
+
+	var opErr *net.OpError
+	if errors.As(err, &opErr) {
+		return opErr.Err
+	}
+
+	return err
+}
+
+// privacyHash returns a short keyed hash of s.
+func privacyHash(s string) string {
+	if s == "" {
+		return s
+	}
+
+	mac := hmac.New(sha256.New, logging.hashKey)
+	mac.Write([]byte(s))
+
+	return hex.EncodeToString(mac.Sum(nil))[:privacyKeyLength*2]
+}
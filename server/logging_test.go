@@ -0,0 +1,246 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/cashshuffle/cashshuffle/message"
+
+	"github.com/golang/protobuf/proto"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// logBuffer holds log output. Connections log from their own goroutines,
+// so it can be read while it is written.
+type logBuffer struct {
+	mutex sync.Mutex
+	b     bytes.Buffer
+}
+
+func (lb *logBuffer) Write(p []byte) (int, error) {
+	lb.mutex.Lock()
+	defer lb.mutex.Unlock()
+
+	return lb.b.Write(p)
+}
+
+// Bytes returns a copy of the output so far.
+func (lb *logBuffer) Bytes() []byte {
+	lb.mutex.Lock()
+	defer lb.mutex.Unlock()
+
+	return append([]byte(nil), lb.b.Bytes()...)
+}
+
+// String returns the output so far.
+func (lb *logBuffer) String() string {
+	lb.mutex.Lock()
+	defer lb.mutex.Unlock()
+
+	return lb.b.String()
+}
+
+// captureLogs configures logging with opts and returns the buffer log
+// output goes to. The default logging is restored when the test ends.
+func captureLogs(t *testing.T, opts LogOptions) *logBuffer {
+	var b logBuffer
+	out := log.StandardLogger().Out
+	level := log.GetLevel()
+	log.SetOutput(&b)
+	require.NoError(t, ConfigureLogging(opts))
+
+	t.Cleanup(func() {
+		log.SetOutput(out)
+		require.NoError(t, ConfigureLogging(LogOptions{Format: "text", Level: level.String()}))
+	})
+
+	return &b
+}
+
+func TestLogBucketsAreFields(t *testing.T) {
+	b := captureLogs(t, LogOptions{Format: "json", Level: "debug"})
+
+	logBan.Debugf("Remove server ban for %s\n", "8.8.8.8")
+
+	var entry map[string]interface{}
+	require.NoError(t, json.Unmarshal(b.Bytes(), &entry))
+	assert.Equal(t, "ban", entry["bucket"])
+	assert.Equal(t, "debug", entry["level"])
+	assert.Contains(t, entry["msg"], "Remove server ban for 8.8.8.8")
+}
+
+func TestLogLevelAndDisabledBuckets(t *testing.T) {
+	b := captureLogs(t, LogOptions{Format: "json", Level: "info", DisabledBuckets: []string{"blame"}})
+
+	logBan.Debugf("hidden by level\n")
+	logBlame.Warnf("hidden by bucket\n")
+	logListener.Infof("shown\n")
+
+	assert.NotContains(t, b.String(), "hidden")
+	assert.Contains(t, b.String(), "shown")
+	assert.Contains(t, b.String(), `"bucket":"listener"`)
+}
+
+func TestConfigureLoggingRejectsBadOptions(t *testing.T) {
+	assert.Error(t, ConfigureLogging(LogOptions{Format: "xml", Level: "info"}))
+	assert.Error(t, ConfigureLogging(LogOptions{Format: "text", Level: "loud"}))
+	assert.Error(t, ConfigureLogging(LogOptions{Format: "text", Level: "info", DisabledBuckets: []string{"nope"}}))
+
+	_, err := ParsePrivacyMode("scramble")
+	assert.Error(t, err)
+}
+
+func TestLogPrivacyHash(t *testing.T) {
+	b := captureLogs(t, LogOptions{Format: "text", Level: "debug", Privacy: PrivacyHash})
+
+	player := &PlayerData{
+		conn:            newIPConn("8.8.8.8"),
+		verificationKey: "02a1b2c3d4e5f6",
+		pool:            &Pool{num: 1},
+	}
+	logBlame.Debugf("From: %s\n", player)
+	logCommunication.Debugf("Received from %s: %s\n", logIP("8.8.8.8"), logData("packet with 02a1b2c3d4e5f6"))
+
+	out := b.String()
+	assert.NotContains(t, out, "8.8.8.8")
+	assert.NotContains(t, out, "02a1b2c3d4e5f6")
+	assert.Contains(t, out, privacyHash("8.8.8.8"))
+	assert.Contains(t, out, privacyHash("02a1b2c3d4e5f6"))
+	assert.Contains(t, out, redacted)
+
+	// the same value hashes the same way within a run
+	assert.Equal(t, logIP("8.8.8.8"), logIP("8.8.8.8"))
+	assert.NotEqual(t, logIP("8.8.8.8"), logIP("8.8.4.4"))
+}
+
+func TestDuplicateKeyIsNotLogged(t *testing.T) {
+	b := captureLogs(t, LogOptions{Format: "text", Level: "debug", Privacy: PrivacyHash})
+	h := newTestHarness(t, basicPoolSize)
+
+	// a key long enough not to turn up in hashes by chance
+	first := newTestClient(h)
+	first.verificationKey = "02a1b2c3d4e5f6a7b8c9d0"
+	first.Connect()
+	first.sendRegistration(testVersion)
+	first.popServerPacket()
+
+	second := newTestClient(h)
+	second.verificationKey = first.verificationKey
+	second.Connect()
+	second.sendRegistration(testVersion)
+	h.WaitNotConnected(second)
+
+	assert.Contains(t, b.String(), errDuplicateKey.Error())
+	assert.NotContains(t, b.String(), first.verificationKey)
+	assert.Contains(t, b.String(), privacyHash(first.verificationKey))
+}
+
+func TestLogPrivacyTruncate(t *testing.T) {
+	captureLogs(t, LogOptions{Format: "text", Level: "debug", Privacy: PrivacyTruncate})
+
+	assert.Equal(t, "8.8.8.0/24", logIP("8.8.8.8"))
+	assert.Equal(t, "2001:db8:1::/48", logIP("2001:db8:1:2::1"))
+	assert.Equal(t, "2001:db8:1::/48", logBanKey("2001:db8:1:2::/64"))
+	assert.Equal(t, "02a1b2c3...", logKey("02a1b2c3d4e5f6"))
+	assert.Equal(t, torBanKeyPrefix+"02a1b2c3...", logBanKey(torBanKeyPrefix+"02a1b2c3d4e5f6"))
+	assert.Equal(t, torCircuitBanKeyPrefix+"5", logBanKey(torCircuitBanKeyPrefix+"5"))
+
+	err := &net.OpError{
+		Op:     "write",
+		Net:    "tcp",
+		Source: &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 1337},
+		Addr:   &net.TCPAddr{IP: net.ParseIP("8.8.8.8"), Port: 5555},
+		Err:    errors.New("broken pipe"),
+	}
+	assert.Equal(t, "broken pipe", logError(err).Error())
+}
+
+func TestLogPrivacyNone(t *testing.T) {
+	captureLogs(t, LogOptions{Format: "text", Level: "debug"})
+
+	assert.Equal(t, "8.8.8.8", logIP("8.8.8.8"))
+	assert.Equal(t, "02a1b2c3d4e5f6", logKey("02a1b2c3d4e5f6"))
+	assert.Equal(t, "packet", logData("packet"))
+	assert.True(t, strings.Contains(logError(&net.OpError{Op: "write", Net: "tcp", Err: errors.New("broken pipe")}).Error(), "write tcp"))
+}
+
+func TestLogNoLinkage(t *testing.T) {
+	b := captureLogs(t, LogOptions{Format: "json", Level: "debug", NoLinkage: true})
+
+	ips := []string{"8.8.8.8", "2001:db8:1:2::1"}
+	vks := []string{"02a1b2c3d4e5f6", "03f6e5d4c3b2a1"}
+
+	tracker := NewTracker(2, 1337, 1338, 1339, 1340)
+	players := make([]*PlayerData, len(ips))
+	for i := range ips {
+		players[i] = &PlayerData{
+			conn:            newIPConn(ips[i]),
+			verificationKey: vks[i],
+			blamedBy:        make(map[string]interface{}),
+			amount:          testAmount,
+			version:         testVersion,
+		}
+		require.NoError(t, tracker.add(players[i]))
+	}
+
+	blame := &message.Packets{
+		Packet: []*message.Signed{{
+			Packet: &message.Packet{
+				FromKey: &message.VerificationKey{Key: vks[0]},
+				Message: &message.Message{
+					Blame: &message.Blame{
+						Reason:  message.Reason_LIAR,
+						Accused: &message.VerificationKey{Key: vks[1]},
+					},
+				},
+			},
+		}},
+	}
+	raw, err := proto.Marshal(blame)
+	require.NoError(t, err)
+
+	c := make(chan *packetInfo, 1)
+	require.NoError(t, sendToPacketInfoChan(bytes.NewBuffer(raw), players[0].conn, c, tracker))
+	pi := <-c
+	require.NoError(t, pi.checkBlameMessage())
+	pi.broadcastAll(blame.Packet)
+
+	logBan.Debugf("Remove player pair %s, %s\n", logBanKey(ips[0]), logBanKey(torBanKeyPrefix+vks[1]))
+	logBroadcast.Debugf("Continuing to send after write error: %s\nTo: %s\n", logError(&net.OpError{
+		Op:   "write",
+		Net:  "tcp",
+		Addr: &net.TCPAddr{IP: net.ParseIP(ips[1]), Port: 5555},
+		Err:  errors.New("broken pipe"),
+	}), players[1])
+
+	var sawIP, sawKey bool
+	for _, line := range strings.Split(strings.TrimSpace(b.String()), "\n") {
+		var entry map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(line), &entry))
+		msg := entry["msg"].(string)
+
+		hasIP := strings.Contains(msg, ips[0]) || strings.Contains(msg, ips[1])
+		hasKey := strings.Contains(msg, vks[0]) || strings.Contains(msg, vks[1])
+		assert.False(t, hasIP && hasKey, "linked log line: %s", msg)
+
+		sawIP = sawIP || hasIP
+		sawKey = sawKey || hasKey
+	}
+
+	// the lines are still logged, with one side of the link left out
+	assert.True(t, sawIP)
+	assert.True(t, sawKey)
+
+	stats, err := json.Marshal(tracker.Stats(ips[0], false))
+	require.NoError(t, err)
+	for _, vk := range vks {
+		assert.NotContains(t, string(stats), vk)
+	}
+}
@@ -10,8 +10,6 @@ import (
 	"github.com/cashshuffle/cashshuffle/message"
 
 	"github.com/golang/protobuf/proto"
-
-	log "github.com/sirupsen/logrus"
 )
 
 const (
@@ -33,8 +31,8 @@ func startPacketInfoChan(c chan *packetInfo) {
 		pi := <-c
 		err := pi.processReceivedMessage()
 		if err != nil {
-			pi.conn.Close()
-			log.Warnf(logCommunication+"Message processor error: %s\n", err)
+			pi.tracker.kick(pi.conn)
+			logCommunication.Warnf("Message processor error: %s\n", logError(err))
 		}
 	}
 }
@@ -45,6 +43,10 @@ func (pi *packetInfo) processReceivedMessage() error {
 	// If we are not tracking the connection yet, the user must be
 	// registering with the server.
 	if pi.tracker.playerByConnection(pi.conn) == nil {
+		if pi.wantsResume() {
+			return pi.resumeSession()
+		}
+
 		err := pi.registerClient()
 		if err != nil {
 			return err
@@ -91,7 +93,17 @@ func (pi *packetInfo) processReceivedMessage() error {
 
 // processMessages reads messages from the connection and begins processing.
 func processMessages(conn net.Conn, c chan *packetInfo, t *Tracker) {
-	defer t.remove(conn)
+	// clients whose connection fails may resume, but not those sending
+	// something the server stops reading at. Connections the server
+	// closes itself are kicked, so their read errors do not hold either.
+	var dropped bool
+	defer func() {
+		if dropped {
+			t.drop(conn)
+		} else {
+			t.remove(conn)
+		}
+	}()
 
 	scanner := bufio.NewScanner(conn)
 	scanner.Split(bufio.ScanBytes)
@@ -113,12 +125,12 @@ func processMessages(conn net.Conn, c chan *packetInfo, t *Tracker) {
 					validMagic, numReadBytes = processFrame(&b)
 
 					if !validMagic {
-						log.Warn(logCommunication + "Invalid magic\n")
+						logCommunication.Warn("Invalid magic\n")
 						return
 					}
 
 					if numReadBytes <= 0 || numReadBytes > maxMessageLength {
-						log.Warnf(logCommunication+"Invalid message length: %d\n", numReadBytes)
+						logCommunication.Warnf("Invalid message length: %d\n", numReadBytes)
 						return
 					}
 
@@ -131,7 +143,7 @@ func processMessages(conn net.Conn, c chan *packetInfo, t *Tracker) {
 			if b.Len() >= numReadBytes {
 				msg := make([]byte, numReadBytes)
 				if _, err := b.Read(msg); err != nil {
-					log.Warnf(logCommunication+"Error reading from message buffer: %s\n", err)
+					logCommunication.Warnf("Error reading from message buffer: %s\n", logError(err))
 					return
 				}
 
@@ -143,12 +155,15 @@ func processMessages(conn net.Conn, c chan *packetInfo, t *Tracker) {
 		}
 
 		if err := scanner.Err(); err != nil {
-			log.Warnf(logCommunication+"Error scanning message: %s\n", err)
+			dropped = true
+			logCommunication.Warnf("Error scanning message: %s\n", logError(err))
 			return
 		}
 
+		// the scanner only stops without a message at EOF
 		if mb.Len() == 0 {
-			log.Warn(logCommunication + "0-length message\n")
+			dropped = true
+			logCommunication.Warn("0-length message\n")
 			return
 		}
 
@@ -158,11 +173,11 @@ func processMessages(conn net.Conn, c chan *packetInfo, t *Tracker) {
 			// disconnected for some reason. Do not consider the read itself
 			// a failure due to failure to set the deadline. The client will drop
 			// off eventually after connection is broken anyway.
-			log.Warnf(logCommunication+"Error setting deadline after successful receive: %s\n", err)
+			logCommunication.Warnf("Error setting deadline after successful receive: %s\n", logError(err))
 		}
 
 		if err := sendToPacketInfoChan(&mb, conn, c, t); err != nil {
-			log.Warnf(logCommunication+"Error sending packet: %s\n", err)
+			logCommunication.Warnf("Error sending packet: %s\n", logError(err))
 			return
 		}
 	}
@@ -191,11 +206,11 @@ func sendToPacketInfoChan(b *bytes.Buffer, conn net.Conn, c chan *packetInfo, t
 
 	err := proto.Unmarshal(b.Bytes(), pdata)
 	if err != nil {
-		log.Debugf(logCommunication+"Unmarshal failed: %v\n", b.Bytes())
+		logCommunication.Debugf("Unmarshal failed: %v\n", logData(b.Bytes()))
 		return err
 	}
 
-	log.Debugf(logCommunication+"Received from %s: %s\n", getIP(conn), pdata)
+	logCommunication.Debugf("Received from %s: %s\n", logLinkedIP(getIP(conn)), logData(pdata))
 
 	data := &packetInfo{
 		message: pdata,
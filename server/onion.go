@@ -0,0 +1,193 @@
+package server
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// torControlOK is the status code of a successful control port reply.
+	torControlOK = 250
+
+	// onionKeyType is the key type requested for new onion services.
+	onionKeyType = "ED25519-V3"
+)
+
+// OnionPort maps a port on the onion service to a local listener.
+type OnionPort struct {
+	VirtualPort int
+	Target      string
+}
+
+// OnionService is an onion service published through the Tor control port.
+// The service is removed by Tor when it is closed.
+type OnionService struct {
+	Address string
+	conn    *textproto.Conn
+}
+
+// PublishOnion connects to the Tor control port at controlAddr and adds an
+// onion service for ports. The onion key is read from keyPath, or created
+// and saved there on first use so the .onion address stays the same across
+// restarts. If password is empty, cookie or null authentication is used.
+func PublishOnion(controlAddr string, password string, keyPath string, ports []OnionPort) (*OnionService, error) {
+	if len(ports) == 0 {
+		return nil, errors.New("no ports for onion service")
+	}
+
+	conn, err := textproto.Dial("tcp", controlAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := authenticateTorControl(conn, password); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	key, err := readOnionKey(keyPath)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	cmd := "ADD_ONION " + key
+	if key == "" {
+		cmd = fmt.Sprintf("ADD_ONION NEW:%s", onionKeyType)
+	}
+	for _, p := range ports {
+		cmd += fmt.Sprintf(" Port=%d,%s", p.VirtualPort, p.Target)
+	}
+
+	reply, err := torControlCommand(conn, cmd)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	s := &OnionService{conn: conn}
+	for _, line := range reply {
+		switch {
+		case strings.HasPrefix(line, "ServiceID="):
+			s.Address = strings.TrimPrefix(line, "ServiceID=") + ".onion"
+		case strings.HasPrefix(line, "PrivateKey="):
+			if err := writeOnionKey(keyPath, strings.TrimPrefix(line, "PrivateKey=")); err != nil {
+				conn.Close()
+				return nil, err
+			}
+		}
+	}
+
+	if s.Address == "" {
+		conn.Close()
+		return nil, errors.New("tor did not return an onion address")
+	}
+
+	logListener.Infof("Published onion service %s\n", s.Address)
+
+	return s, nil
+}
+
+// Close closes the control connection, which removes the onion service.
+func (s *OnionService) Close() error {
+	return s.conn.Close()
+}
+
+// authenticateTorControl authenticates with the password if one is set,
+// otherwise with the auth cookie or no credentials as the control port
+// allows.
+func authenticateTorControl(conn *textproto.Conn, password string) error {
+	if password != "" {
+		_, err := torControlCommand(conn, fmt.Sprintf("AUTHENTICATE %q", password))
+		return err
+	}
+
+	reply, err := torControlCommand(conn, "PROTOCOLINFO 1")
+	if err != nil {
+		return err
+	}
+
+	var methods []string
+	var cookieFile string
+	for _, line := range reply {
+		if !strings.HasPrefix(line, "AUTH ") {
+			continue
+		}
+
+		for _, field := range strings.Fields(strings.TrimPrefix(line, "AUTH ")) {
+			switch {
+			case strings.HasPrefix(field, "METHODS="):
+				methods = strings.Split(strings.TrimPrefix(field, "METHODS="), ",")
+			case strings.HasPrefix(field, "COOKIEFILE="):
+				cookieFile = strings.Trim(strings.TrimPrefix(field, "COOKIEFILE="), `"`)
+			}
+		}
+	}
+
+	for _, m := range methods {
+		switch m {
+		case "NULL":
+			_, err := torControlCommand(conn, "AUTHENTICATE")
+			return err
+		case "COOKIE":
+			cookie, err := ioutil.ReadFile(cookieFile)
+			if err != nil {
+				return err
+			}
+
+			_, err = torControlCommand(conn, "AUTHENTICATE "+hex.EncodeToString(cookie))
+			return err
+		}
+	}
+
+	return fmt.Errorf("no supported tor control auth method in %v, set a control password", methods)
+}
+
+// torControlCommand sends a command to the control port and returns the
+// lines of a successful reply.
+func torControlCommand(conn *textproto.Conn, cmd string) ([]string, error) {
+	id, err := conn.Cmd("%s", cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	conn.StartResponse(id)
+	defer conn.EndResponse(id)
+
+	_, msg, err := conn.ReadResponse(torControlOK)
+	if err != nil {
+		return nil, fmt.Errorf("tor control: %s", err)
+	}
+
+	return strings.Split(msg, "\n"), nil
+}
+
+// readOnionKey reads a saved onion key. An empty key is returned if
+// none has been saved yet.
+func readOnionKey(keyPath string) (string, error) {
+	b, err := ioutil.ReadFile(keyPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+
+		return "", err
+	}
+
+	return strings.TrimSpace(string(b)), nil
+}
+
+// writeOnionKey saves an onion key so that only the owner can read it.
+func writeOnionKey(keyPath string, key string) error {
+	if err := os.MkdirAll(filepath.Dir(keyPath), 0700); err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(keyPath, []byte(key+"\n"), 0600)
+}
@@ -0,0 +1,174 @@
+package server
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/textproto"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testServiceID  = "abcdefghijklmnopqrstuvwxyz234567abcdefghijklmnopqrstuvwx"
+	testPrivateKey = "ED25519-V3:c2VjcmV0"
+)
+
+var testOnionPorts = []OnionPort{
+	{VirtualPort: 1339, Target: "127.0.0.1:1339"},
+	{VirtualPort: 8081, Target: "127.0.0.1:8081"},
+}
+
+func TestPublishOnionNewKey(t *testing.T) {
+	control := newFakeTorControl(t, "METHODS=NULL")
+	keyPath := filepath.Join(t.TempDir(), "onion.key")
+
+	s, err := PublishOnion(control.addr, "", keyPath, testOnionPorts)
+	require.NoError(t, err)
+	defer s.Close()
+
+	assert.Equal(t, testServiceID+".onion", s.Address)
+	assert.Equal(t, []string{
+		"PROTOCOLINFO 1",
+		"AUTHENTICATE",
+		"ADD_ONION NEW:ED25519-V3 Port=1339,127.0.0.1:1339 Port=8081,127.0.0.1:8081",
+	}, control.commands())
+
+	key, err := ioutil.ReadFile(keyPath)
+	require.NoError(t, err)
+	assert.Equal(t, testPrivateKey+"\n", string(key))
+}
+
+func TestPublishOnionSavedKey(t *testing.T) {
+	control := newFakeTorControl(t, "METHODS=HASHEDPASSWORD")
+	keyPath := filepath.Join(t.TempDir(), "onion.key")
+	require.NoError(t, writeOnionKey(keyPath, testPrivateKey))
+
+	s, err := PublishOnion(control.addr, "secret", keyPath, testOnionPorts[:1])
+	require.NoError(t, err)
+	defer s.Close()
+
+	assert.Equal(t, testServiceID+".onion", s.Address)
+	assert.Equal(t, []string{
+		`AUTHENTICATE "secret"`,
+		"ADD_ONION " + testPrivateKey + " Port=1339,127.0.0.1:1339",
+	}, control.commands())
+}
+
+func TestPublishOnionCookieAuth(t *testing.T) {
+	cookieFile := filepath.Join(t.TempDir(), "control_auth_cookie")
+	require.NoError(t, ioutil.WriteFile(cookieFile, []byte{1, 2, 3}, 0600))
+
+	control := newFakeTorControl(t, fmt.Sprintf("METHODS=COOKIE,SAFECOOKIE COOKIEFILE=%q", cookieFile))
+	keyPath := filepath.Join(t.TempDir(), "onion.key")
+
+	s, err := PublishOnion(control.addr, "", keyPath, testOnionPorts[:1])
+	require.NoError(t, err)
+	defer s.Close()
+
+	assert.Contains(t, control.commands(), "AUTHENTICATE "+hex.EncodeToString([]byte{1, 2, 3}))
+}
+
+func TestPublishOnionAuthFailure(t *testing.T) {
+	control := newFakeTorControl(t, "METHODS=NULL")
+	control.password = "right"
+
+	_, err := PublishOnion(control.addr, "wrong", filepath.Join(t.TempDir(), "onion.key"), testOnionPorts)
+	assert.Error(t, err)
+
+	_, err = PublishOnion(control.addr, "", filepath.Join(t.TempDir(), "onion.key"), nil)
+	assert.Error(t, err)
+}
+
+func TestPublishOnionUnsupportedAuth(t *testing.T) {
+	control := newFakeTorControl(t, "METHODS=HASHEDPASSWORD")
+
+	_, err := PublishOnion(control.addr, "", filepath.Join(t.TempDir(), "onion.key"), testOnionPorts)
+	assert.Error(t, err)
+}
+
+// fakeTorControl is a minimal Tor control port that records commands.
+type fakeTorControl struct {
+	addr     string
+	auth     string
+	password string
+	mutex    sync.Mutex
+	received []string
+}
+
+// newFakeTorControl starts a fake control port with the AUTH line
+// returned by PROTOCOLINFO.
+func newFakeTorControl(t *testing.T, auth string) *fakeTorControl {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { l.Close() })
+
+	c := &fakeTorControl{
+		addr: l.Addr().String(),
+		auth: auth,
+	}
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go c.serve(textproto.NewConn(conn))
+		}
+	}()
+
+	return c
+}
+
+func (c *fakeTorControl) serve(conn *textproto.Conn) {
+	defer conn.Close()
+
+	for {
+		line, err := conn.ReadLine()
+		if err != nil {
+			return
+		}
+
+		c.mutex.Lock()
+		c.received = append(c.received, line)
+		c.mutex.Unlock()
+
+		switch {
+		case line == "PROTOCOLINFO 1":
+			conn.PrintfLine("250-PROTOCOLINFO 1")
+			conn.PrintfLine("250-AUTH %s", c.auth)
+			conn.PrintfLine(`250-VERSION Tor="0.4.8.9"`)
+			conn.PrintfLine("250 OK")
+		case strings.HasPrefix(line, "AUTHENTICATE"):
+			if c.password != "" && line != fmt.Sprintf("AUTHENTICATE %q", c.password) {
+				conn.PrintfLine("515 Authentication failed")
+				continue
+			}
+			conn.PrintfLine("250 OK")
+		case strings.HasPrefix(line, "ADD_ONION NEW:"):
+			conn.PrintfLine("250-ServiceID=%s", testServiceID)
+			conn.PrintfLine("250-PrivateKey=%s", testPrivateKey)
+			conn.PrintfLine("250 OK")
+		case strings.HasPrefix(line, "ADD_ONION "):
+			conn.PrintfLine("250-ServiceID=%s", testServiceID)
+			conn.PrintfLine("250 OK")
+		default:
+			conn.PrintfLine("510 Unrecognized command")
+		}
+	}
+}
+
+// commands returns the commands received so far.
+func (c *fakeTorControl) commands() []string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return append([]string{}, c.received...)
+}
@@ -0,0 +1,242 @@
+package server
+
+// StatsOpenAPI is the OpenAPI description of the versioned stats API. It
+// is served on /v1/openapi.json and must be kept in step with StatsV1
+// and RoundStats.
+const StatsOpenAPI = `{
+  "openapi": "3.0.3",
+  "info": {
+    "title": "CashShuffle Stats",
+    "version": "1"
+  },
+  "paths": {
+    "/v1/stats": {
+      "get": {
+        "summary": "Server stats and a page of its pools",
+        "parameters": [
+          {
+            "name": "amount",
+            "in": "query",
+            "description": "Only pools shuffling this amount of satoshis.",
+            "schema": {"type": "integer", "format": "int64", "minimum": 0}
+          },
+          {
+            "name": "type",
+            "in": "query",
+            "description": "Only pools of this shuffle type, ignoring case.",
+            "schema": {"type": "string", "example": "DEFAULT"}
+          },
+          {
+            "name": "version",
+            "in": "query",
+            "description": "Only pools of this protocol version.",
+            "schema": {"type": "integer", "format": "int64", "minimum": 0}
+          },
+          {
+            "name": "full",
+            "in": "query",
+            "description": "Only full or only open pools.",
+            "schema": {"type": "boolean"}
+          },
+          {
+            "name": "after",
+            "in": "query",
+            "description": "Only pools with a larger ID, taken from next of the previous page.",
+            "schema": {"type": "integer", "minimum": 0}
+          },
+          {
+            "name": "limit",
+            "in": "query",
+            "description": "The most pools to return.",
+            "schema": {"type": "integer", "minimum": 1, "maximum": 500, "default": 50}
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "The stats.",
+            "headers": {
+              "X-CashShuffle-Public-Key": {
+                "description": "The base64 ed25519 public key of the server, also served on /identity.",
+                "schema": {"type": "string"}
+              },
+              "X-CashShuffle-Signed-At": {
+                "description": "The unix time the response was signed.",
+                "schema": {"type": "integer", "format": "int64"}
+              },
+              "X-CashShuffle-Signature": {
+                "description": "The base64 ed25519 signature of cashshuffle/stats/v1, a zero byte, X-CashShuffle-Signed-At, a newline and the body.",
+                "schema": {"type": "string"}
+              }
+            },
+            "content": {
+              "application/json": {
+                "schema": {"$ref": "#/components/schemas/Stats"}
+              }
+            }
+          },
+          "400": {
+            "description": "A query parameter is invalid.",
+            "content": {
+              "application/json": {
+                "schema": {"$ref": "#/components/schemas/Error"}
+              }
+            }
+          },
+          "429": {
+            "description": "The client is rate limited."
+          }
+        }
+      }
+    },
+    "/v1/rounds": {
+      "get": {
+        "summary": "Recent rounds, newest first",
+        "responses": {
+          "200": {
+            "description": "The rounds of the last day, signed like the stats.",
+            "content": {
+              "application/json": {
+                "schema": {"$ref": "#/components/schemas/Rounds"}
+              }
+            }
+          },
+          "404": {
+            "description": "The server does not serve its round history."
+          },
+          "429": {
+            "description": "The client is rate limited."
+          }
+        }
+      }
+    },
+    "/v1/rounds/{id}": {
+      "get": {
+        "summary": "A recent round",
+        "parameters": [
+          {
+            "name": "id",
+            "in": "path",
+            "required": true,
+            "description": "The round ID, as sent in announcements and pool stats.",
+            "schema": {"type": "string"}
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "The round, signed like the stats.",
+            "content": {
+              "application/json": {
+                "schema": {"$ref": "#/components/schemas/Round"}
+              }
+            }
+          },
+          "404": {
+            "description": "The round is unknown or was forgotten, or the server does not serve its round history.",
+            "content": {
+              "application/json": {
+                "schema": {"$ref": "#/components/schemas/Error"}
+              }
+            }
+          },
+          "429": {
+            "description": "The client is rate limited."
+          }
+        }
+      }
+    }
+  },
+  "components": {
+    "schemas": {
+      "Stats": {
+        "type": "object",
+        "required": ["banScore", "banned", "connections", "poolSize", "shufflePort", "shuffleWebSocketPort", "rejections", "draining", "tiers", "pools", "total"],
+        "properties": {
+          "banScore": {"type": "integer", "description": "The ban score of the client."},
+          "banned": {"type": "boolean", "description": "Whether the client is banned."},
+          "connections": {"type": "integer"},
+          "poolSize": {"type": "integer", "description": "The number of players in new pools."},
+          "shufflePort": {"type": "integer"},
+          "shuffleWebSocketPort": {"type": "integer"},
+          "rejections": {"$ref": "#/components/schemas/Rejections"},
+          "onionAddress": {"type": "string"},
+          "draining": {"type": "boolean", "description": "Whether new players are refused."},
+          "tiers": {"type": "array", "items": {"$ref": "#/components/schemas/Tier"}},
+          "publicKey": {"type": "string", "description": "The base64 ed25519 public key server packets and stats are signed with."},
+          "signedPacketsVersion": {"type": "integer", "format": "int64", "description": "The first protocol version whose clients are sent signed server packets."},
+          "versions": {"type": "array", "items": {"type": "integer", "format": "int64"}, "description": "The protocol versions the server supports, missing if it supports every version."},
+          "pools": {"type": "array", "items": {"$ref": "#/components/schemas/Pool"}},
+          "total": {"type": "integer", "description": "The number of pools matching the filters."},
+          "next": {"type": "integer", "description": "The after parameter of the next page, missing on the last page."}
+        }
+      },
+      "Pool": {
+        "type": "object",
+        "required": ["id", "members", "amount", "type", "full", "version", "createdAt", "sinceLastJoin"],
+        "properties": {
+          "id": {"type": "integer", "description": "Identifies the pool. IDs are not reused until the server restarts."},
+          "members": {"type": "integer"},
+          "amount": {"type": "integer", "format": "int64"},
+          "type": {"type": "string"},
+          "full": {"type": "boolean"},
+          "version": {"type": "integer", "format": "int64"},
+          "createdAt": {"type": "integer", "format": "int64", "description": "Unix time the pool was created."},
+          "sinceLastJoin": {"type": "integer", "format": "int64", "description": "Seconds since a player last joined."},
+          "round": {"type": "string", "description": "The ID of the round of a full pool."}
+        }
+      },
+      "Rounds": {
+        "type": "object",
+        "required": ["rounds"],
+        "properties": {
+          "rounds": {"type": "array", "items": {"$ref": "#/components/schemas/Round"}}
+        }
+      },
+      "Round": {
+        "type": "object",
+        "required": ["id", "pool", "amount", "type", "version", "players", "startedAt", "outcome"],
+        "properties": {
+          "id": {"type": "string", "description": "Identifies the round. IDs are never reused."},
+          "pool": {"type": "integer", "description": "The ID of the pool that started the round."},
+          "amount": {"type": "integer", "format": "int64"},
+          "type": {"type": "string"},
+          "version": {"type": "integer", "format": "int64"},
+          "players": {"type": "integer"},
+          "startedAt": {"type": "integer", "format": "int64", "description": "Unix time the pool filled up."},
+          "endedAt": {"type": "integer", "format": "int64", "description": "Unix time the last player left, missing while running."},
+          "outcome": {"type": "string", "enum": ["running", "completed", "banned", "abandoned"], "description": "A round completes if its players got to the verification and submission phase without a ban, and is abandoned if they left earlier."}
+        }
+      },
+      "Tier": {
+        "type": "object",
+        "required": ["amount", "type", "version", "pools", "members", "completed1h", "completed24h"],
+        "properties": {
+          "amount": {"type": "integer", "format": "int64"},
+          "type": {"type": "string"},
+          "version": {"type": "integer", "format": "int64"},
+          "pools": {"type": "integer"},
+          "members": {"type": "integer"},
+          "completed1h": {"type": "integer", "description": "Rounds completed in the last hour."},
+          "completed24h": {"type": "integer", "description": "Rounds completed in the last day."}
+        }
+      },
+      "Rejections": {
+        "type": "object",
+        "description": "Connections refused by each limit since the server started.",
+        "properties": {
+          "maxConnections": {"type": "integer"},
+          "maxConnectionsPerIP": {"type": "integer"},
+          "maxConnectionsPerPrefix": {"type": "integer"},
+          "maxPoolsPerIP": {"type": "integer"}
+        }
+      },
+      "Error": {
+        "type": "object",
+        "required": ["error"],
+        "properties": {
+          "error": {"type": "string"}
+        }
+      }
+    }
+  }
+}
+`
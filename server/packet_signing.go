@@ -0,0 +1,52 @@
+package server
+
+import (
+	"crypto/ed25519"
+
+	"github.com/cashshuffle/cashshuffle/message"
+
+	"github.com/golang/protobuf/proto"
+)
+
+const (
+	// packetContext is the signing context of packets sent by the server.
+	packetContext = "cashshuffle/packet/v1"
+
+	// SignedPacketsVersion is the first protocol version whose clients
+	// are sent signed server packets. Clients that require signed packets
+	// register with this version or later, which also keeps them in pools
+	// with clients that expect the same.
+	SignedPacketsVersion = 301
+)
+
+// signPacket signs a packet sent by the server. The signature covers the
+// packet context, a zero byte and the serialized packet.
+func (id *Identity) signPacket(p *message.Packet) (*message.Signature, error) {
+	b, err := proto.Marshal(p)
+	if err != nil {
+		return nil, err
+	}
+
+	return &message.Signature{
+		Signature: ed25519.Sign(id.key, signedBytes(packetContext, b)),
+	}, nil
+}
+
+// serverPacket wraps a packet the server sends to a client registered
+// with version. If the server has an identity and the client supports
+// signed packets, the packet is bound to the client's session and
+// signed, so that it can not be forged or replayed to another session.
+func serverPacket(id *Identity, version uint64, session []byte, p *message.Packet) (*message.Signed, error) {
+	if id == nil || version < SignedPacketsVersion {
+		return &message.Signed{Packet: p}, nil
+	}
+
+	p.Session = session
+
+	sig, err := id.signPacket(p)
+	if err != nil {
+		return nil, err
+	}
+
+	return &message.Signed{Packet: p, Signature: sig}, nil
+}
@@ -0,0 +1,112 @@
+package server
+
+import (
+	"crypto/ed25519"
+	"testing"
+
+	"github.com/cashshuffle/cashshuffle/message"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sendRegistration registers a connected client without waiting for
+// the reply.
+func (c *testClient) sendRegistration(version uint64) {
+	c.version = version
+
+	err := writeMessage(c.conn, []*message.Signed{{
+		Packet: &message.Packet{
+			FromKey:      &message.VerificationKey{Key: c.verificationKey},
+			Registration: &message.Registration{Amount: testAmount, Version: version},
+		},
+	}})
+	require.NoError(c.h.t, err)
+}
+
+// popServerPacket returns the next packet sent to the client.
+func (c *testClient) popServerPacket() *message.Signed {
+	pi, err := c.inbox.PopOldest()
+	require.NoError(c.h.t, err)
+	require.Len(c.h.t, pi.message.GetPacket(), 1)
+
+	return pi.message.GetPacket()[0]
+}
+
+// assertSignedFor checks that the server signed a packet for session.
+func assertSignedFor(t *testing.T, key ed25519.PublicKey, signed *message.Signed, session []byte) {
+	require.NotNil(t, signed.GetSignature())
+	assert.Equal(t, session, signed.GetPacket().GetSession())
+
+	b, err := proto.Marshal(signed.GetPacket())
+	require.NoError(t, err)
+	assert.True(t, ed25519.Verify(key, signedBytes(packetContext, b), signed.GetSignature().GetSignature()))
+}
+
+func TestServerPacketsAreSigned(t *testing.T) {
+	h := newTestHarness(t, 2)
+
+	key := newTestKey(t)
+	h.tracker.SetIdentity(NewIdentity(key, ServerAnnouncement{}, h.tracker))
+	public := key.Public().(ed25519.PublicKey)
+
+	first := newTestClient(h)
+	first.Connect()
+	first.sendRegistration(SignedPacketsVersion)
+
+	registered := first.popServerPacket()
+	first.session = registered.GetPacket().GetSession()
+	require.NotEmpty(t, first.session)
+	assertSignedFor(t, public, registered, first.session)
+
+	assertSignedFor(t, public, first.popServerPacket(), first.session)
+
+	second := newTestClient(h)
+	second.Connect()
+	second.sendRegistration(SignedPacketsVersion)
+
+	registered = second.popServerPacket()
+	second.session = registered.GetPacket().GetSession()
+	assertSignedFor(t, public, registered, second.session)
+
+	// each player gets an announcement bound to their own session
+	for _, c := range []*testClient{first, second} {
+		announcement := c.popServerPacket()
+		assert.Equal(t, message.Phase_ANNOUNCEMENT, announcement.GetPacket().GetPhase())
+		assertSignedFor(t, public, announcement, c.session)
+	}
+
+	// a forged packet does not verify
+	forged := proto.Clone(registered).(*message.Signed)
+	forged.Packet.Number++
+	b, err := proto.Marshal(forged.GetPacket())
+	require.NoError(t, err)
+	assert.False(t, ed25519.Verify(public, signedBytes(packetContext, b), forged.GetSignature().GetSignature()))
+
+	// registration failures are signed without a session
+	anonymous := newTestClient(h)
+	anonymous.verificationKey = ""
+	anonymous.Connect()
+	anonymous.sendRegistration(SignedPacketsVersion)
+	assertSignedFor(t, public, anonymous.popServerPacket(), nil)
+
+	h.WaitEmptyInboxes([]*testClient{first, second})
+}
+
+func TestOlderClientsGetUnsignedPackets(t *testing.T) {
+	h := newTestHarness(t, 2)
+	h.tracker.SetIdentity(NewIdentity(newTestKey(t), ServerAnnouncement{}, h.tracker))
+
+	c := newTestClient(h)
+	c.Connect()
+	c.sendRegistration(SignedPacketsVersion - 1)
+
+	registered := c.popServerPacket()
+	assert.Nil(t, registered.GetSignature())
+	assert.NotEmpty(t, registered.GetPacket().GetSession())
+
+	joined := c.popServerPacket()
+	assert.Nil(t, joined.GetSignature())
+	assert.Empty(t, joined.GetPacket().GetSession())
+}
@@ -11,6 +11,7 @@ import (
 // PlayerData is data needed about each connection.
 type PlayerData struct {
 	mutex           sync.RWMutex
+	writeMutex      sync.Mutex
 	sessionID       []byte
 	number          uint32
 	conn            net.Conn
@@ -21,6 +22,20 @@ type PlayerData struct {
 	version         uint64
 	shuffleType     message.ShuffleType
 	isPassive       bool
+	tor             bool
+	resumable       bool
+	holds           int
+	lastResume      uint64
+	kicked          bool
+}
+
+// write sends msgs to the player. Writes are serialized, so that the
+// broadcasts replayed to a player that resumes come before new ones.
+func (p *PlayerData) write(msgs []*message.Signed) error {
+	p.writeMutex.Lock()
+	defer p.writeMutex.Unlock()
+
+	return writeMessage(p.conn, msgs)
 }
 
 // addBlame adds a verification key to the blamedBy map.
@@ -41,8 +56,9 @@ func (p *PlayerData) String() string {
 	return fmt.Sprintf("("+
 		"vk:%s, "+
 		"pool:%d, "+
+		"round:%s, "+
 		"num:%d, "+
 		"ip:%s"+
 		")",
-		p.verificationKey, p.pool.num, p.number, getIP(p.conn))
+		logKey(p.verificationKey), p.pool.num, p.pool.Round(), p.number, logLinkedIP(getIP(p.conn)))
 }
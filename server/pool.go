@@ -2,8 +2,11 @@ package server
 
 import (
 	"sync"
+	"time"
 
 	"github.com/cashshuffle/cashshuffle/message"
+
+	"github.com/nats-io/nuid"
 )
 
 const (
@@ -22,6 +25,12 @@ type Pool struct {
 	version        uint64
 	shuffleType    message.ShuffleType
 	frozenSnapshot map[string]*PlayerData // vk > player
+	created        time.Time
+	lastJoin       time.Time
+	round          string
+	frozenAt       time.Time
+	replay         replayBuffer
+	phase          message.Phase
 }
 
 // newPool creates a new pool and enforces the rule that pools only exist
@@ -37,6 +46,7 @@ func newPool(num int, player *PlayerData, size int) *Pool {
 		version:        player.version,
 		shuffleType:    player.shuffleType,
 		frozenSnapshot: make(map[string]*PlayerData),
+		created:        time.Now(),
 	}
 	pool.AddPlayer(player)
 	return pool
@@ -60,6 +70,46 @@ func (pool *Pool) IsBanned(player *PlayerData) bool {
 	return len(player.blamedBy) >= pool.size-1
 }
 
+// LastJoin returns when the last player joined the pool.
+func (pool *Pool) LastJoin() time.Time {
+	pool.mutex.RLock()
+	defer pool.mutex.RUnlock()
+
+	return pool.lastJoin
+}
+
+// Round returns the ID of the round the pool started when it froze, or
+// an empty string if it is not frozen. Round IDs are unique, unlike pool
+// numbers which start from 1 when the server restarts.
+func (pool *Pool) Round() string {
+	pool.mutex.RLock()
+	defer pool.mutex.RUnlock()
+
+	return pool.round
+}
+
+// recordPhase remembers the furthest phase the players of the pool
+// broadcast in msgs.
+func (pool *Pool) recordPhase(msgs []*message.Signed) {
+	pool.mutex.Lock()
+	defer pool.mutex.Unlock()
+
+	for _, m := range msgs {
+		if phase := m.GetPacket().GetPhase(); phase > pool.phase {
+			pool.phase = phase
+		}
+	}
+}
+
+// reachedPhase returns the furthest phase the players of the pool
+// broadcast in.
+func (pool *Pool) reachedPhase() message.Phase {
+	pool.mutex.RLock()
+	defer pool.mutex.RUnlock()
+
+	return pool.phase
+}
+
 // PlayerCount returns the number of players in a pool.
 func (pool *Pool) PlayerCount() int {
 	pool.mutex.RLock()
@@ -101,9 +151,12 @@ func (pool *Pool) AddPlayer(player *PlayerData) bool {
 	player.number = playerNum
 	player.pool = pool
 	pool.players[player.number] = player
+	pool.lastJoin = time.Now()
 
 	if len(pool.players) == pool.size {
 		pool.frozenSnapshot = pool.takeSnapshot()
+		pool.round = nuid.Next()
+		pool.frozenAt = time.Now()
 	}
 	return true
 }
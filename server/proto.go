@@ -8,8 +8,6 @@ import (
 	"github.com/cashshuffle/cashshuffle/message"
 
 	"github.com/golang/protobuf/proto"
-
-	log "github.com/sirupsen/logrus"
 )
 
 const (
@@ -33,7 +31,7 @@ func writeMessage(conn net.Conn, msgs []*message.Signed) error {
 		return err
 	}
 
-	log.Debugf(logCommunication+"Sent by %s: %s\n", getIP(conn), packets)
+	logCommunication.Debugf("Sent by %s: %s\n", logLinkedIP(getIP(conn)), logData(packets))
 
 	// Extend the deadline, we just sent a message.
 	if err = conn.SetDeadline(time.Now().Add(deadline)); err != nil {
@@ -41,7 +39,7 @@ func writeMessage(conn net.Conn, msgs []*message.Signed) error {
 		// ignored due to some bad behavior. Do not consider the write itself
 		// a failure due to failure to set the deadline. The client will drop
 		// off eventually after connection is broken anyway.
-		log.Debugf(logCommunication+"Error setting deadline after successful write: %s\n", err)
+		logCommunication.Debugf("Error setting deadline after successful write: %s\n", logError(err))
 	}
 
 	return nil
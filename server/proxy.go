@@ -0,0 +1,165 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/pires/go-proxyproto"
+)
+
+// listen creates a TCP listener. If proxyProtocol is not empty, PROXY
+// protocol headers are read from connections from those IPs or CIDRs.
+// Bound listeners are counted by the health endpoints.
+func listen(ip string, port int, proxyProtocol []string, h *Health) (net.Listener, error) {
+	listener, err := net.Listen("tcp", fmt.Sprintf("%s:%d", ip, port))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(proxyProtocol) > 0 {
+		proxyListener, err := createProxyListener(listener, proxyProtocol)
+		if err != nil {
+			listener.Close()
+			return nil, err
+		}
+
+		listener = proxyListener
+	}
+
+	h.listenerBound()
+
+	return listener, nil
+}
+
+// createProxyListener wraps a listener so that connections from trusted
+// sources can pass the real client address with a PROXY protocol v1 or v2
+// header. Connections from any other source that send a header are
+// rejected, so clients can not spoof their address.
+func createProxyListener(listener net.Listener, trusted []string) (net.Listener, error) {
+	policy, err := proxyproto.StrictWhiteListPolicy(trusted)
+	if err != nil {
+		return nil, err
+	}
+
+	return &proxyproto.Listener{
+		Listener: listener,
+		Policy:   policy,
+	}, nil
+}
+
+// TrustedProxies is a list of networks that are trusted to report the
+// client address in forwarded HTTP headers.
+type TrustedProxies []*net.IPNet
+
+// ParseTrustedProxies parses a list of IPs and CIDRs.
+func ParseTrustedProxies(proxies []string) (TrustedProxies, error) {
+	trusted := make(TrustedProxies, 0, len(proxies))
+
+	for _, p := range proxies {
+		if !strings.Contains(p, "/") {
+			ip := net.ParseIP(p)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid trusted proxy: %s", p)
+			}
+
+			bits := maxIPv6PrefixLength
+			if ip.To4() != nil {
+				bits = maxIPv4PrefixLength
+			}
+			p = fmt.Sprintf("%s/%d", p, bits)
+		}
+
+		_, network, err := net.ParseCIDR(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy: %s", p)
+		}
+
+		trusted = append(trusted, network)
+	}
+
+	return trusted, nil
+}
+
+// contains returns true if the IP belongs to a trusted proxy.
+func (tp TrustedProxies) contains(ip string) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+
+	for _, network := range tp {
+		if network.Contains(parsed) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// clientIP returns the IP of the client that made the request.
+// Forwarded headers are only honored when the request comes from a
+// trusted proxy. The X-Forwarded-For chain is walked from the right,
+// skipping trusted proxies, so that addresses prepended by the client
+// are never used.
+func (tp TrustedProxies) clientIP(r *http.Request) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+
+	if !tp.contains(ip) {
+		return ip
+	}
+
+	forwarded := r.Header.Values("X-Forwarded-For")
+	if len(forwarded) == 0 {
+		if realIP := strings.TrimSpace(r.Header.Get("X-Real-IP")); net.ParseIP(realIP) != nil {
+			return realIP
+		}
+
+		return ip
+	}
+
+	hops := strings.Split(strings.Join(forwarded, ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if net.ParseIP(hop) == nil {
+			break
+		}
+
+		ip = hop
+		if !tp.contains(hop) {
+			break
+		}
+	}
+
+	return ip
+}
+
+// forwardedConn overrides the remote address of a connection with the
+// client address resolved from forwarded headers.
+type forwardedConn struct {
+	net.Conn
+	remoteAddr net.Addr
+}
+
+// newForwardedConn returns a connection that reports ip as the remote
+// address. The connection is returned unchanged if ip is invalid.
+func newForwardedConn(conn net.Conn, ip string) net.Conn {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return conn
+	}
+
+	return &forwardedConn{
+		Conn:       conn,
+		remoteAddr: &net.TCPAddr{IP: parsed},
+	}
+}
+
+// RemoteAddr returns the client address.
+func (c *forwardedConn) RemoteAddr() net.Addr {
+	return c.remoteAddr
+}
@@ -0,0 +1,227 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/pires/go-proxyproto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProxyListenerV1(t *testing.T) {
+	conn, client := acceptProxyConn(t, []string{"127.0.0.1/32"})
+
+	_, err := client.Write([]byte("PROXY TCP4 203.0.113.7 10.0.0.1 5000 1337\r\nhi"))
+	require.NoError(t, err)
+
+	assert.Equal(t, "203.0.113.7", getIP(conn))
+	assertReads(t, conn, "hi")
+}
+
+func TestProxyListenerV2(t *testing.T) {
+	conn, client := acceptProxyConn(t, []string{"127.0.0.1"})
+
+	header := proxyproto.HeaderProxyFromAddrs(2,
+		&net.TCPAddr{IP: net.ParseIP("2001:db8::7"), Port: 5000},
+		&net.TCPAddr{IP: net.ParseIP("2001:db8::1"), Port: 1337},
+	)
+	_, err := header.WriteTo(client)
+	require.NoError(t, err)
+	_, err = client.Write([]byte("hi"))
+	require.NoError(t, err)
+
+	assert.Equal(t, "2001:db8::7", getIP(conn))
+	assertReads(t, conn, "hi")
+}
+
+func TestProxyListenerWithoutHeader(t *testing.T) {
+	conn, client := acceptProxyConn(t, []string{"127.0.0.1/32"})
+
+	_, err := client.Write([]byte("hi"))
+	require.NoError(t, err)
+
+	assert.Equal(t, "127.0.0.1", getIP(conn))
+	assertReads(t, conn, "hi")
+}
+
+func TestProxyListenerUntrustedSource(t *testing.T) {
+	conn, client := acceptProxyConn(t, []string{"10.0.0.0/8"})
+
+	_, err := client.Write([]byte("PROXY TCP4 203.0.113.7 10.0.0.1 5000 1337\r\nhi"))
+	require.NoError(t, err)
+
+	assert.Equal(t, "127.0.0.1", getIP(conn))
+	_, err = conn.Read(make([]byte, 2))
+	assert.Error(t, err)
+}
+
+func TestProxyListenerInvalidTrustedSource(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+
+	_, err = createProxyListener(listener, []string{"not-a-cidr"})
+	assert.Error(t, err)
+}
+
+// acceptProxyConn creates a PROXY protocol listener and returns both
+// ends of a connection to it.
+func acceptProxyConn(t *testing.T, trusted []string) (net.Conn, net.Conn) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	listener, err := createProxyListener(l, trusted)
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+
+	client, err := net.Dial("tcp", listener.Addr().String())
+	require.NoError(t, err)
+	t.Cleanup(func() { client.Close() })
+
+	conn, err := listener.Accept()
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	return conn, client
+}
+
+// assertReads confirms the connection reads the expected data.
+func assertReads(t *testing.T, conn net.Conn, expected string) {
+	b := make([]byte, len(expected))
+	_, err := io.ReadFull(conn, b)
+	require.NoError(t, err)
+	assert.Equal(t, expected, string(b))
+}
+
+func TestParseTrustedProxies(t *testing.T) {
+	trusted, err := ParseTrustedProxies([]string{"10.0.0.0/8", "192.168.1.1", "2001:db8::/32", "::1"})
+	require.NoError(t, err)
+	assert.Len(t, trusted, 4)
+
+	assert.True(t, trusted.contains("10.1.2.3"))
+	assert.True(t, trusted.contains("192.168.1.1"))
+	assert.False(t, trusted.contains("192.168.1.2"))
+	assert.True(t, trusted.contains("2001:db8:1::1"))
+	assert.True(t, trusted.contains("::1"))
+	assert.False(t, trusted.contains("::2"))
+
+	_, err = ParseTrustedProxies([]string{"10.0.0.0/33"})
+	assert.Error(t, err)
+
+	_, err = ParseTrustedProxies([]string{"proxy.example.com"})
+	assert.Error(t, err)
+}
+
+func TestClientIP(t *testing.T) {
+	trusted, err := ParseTrustedProxies([]string{"10.0.0.0/8"})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		proxies    TrustedProxies
+		remoteAddr string
+		headers    map[string][]string
+		expected   string
+	}{
+		{
+			name:       "no trusted proxies",
+			proxies:    nil,
+			remoteAddr: "10.0.0.1:1000",
+			headers:    map[string][]string{"X-Forwarded-For": {"203.0.113.7"}},
+			expected:   "10.0.0.1",
+		},
+		{
+			name:       "untrusted remote",
+			proxies:    trusted,
+			remoteAddr: "198.51.100.1:1000",
+			headers:    map[string][]string{"X-Forwarded-For": {"203.0.113.7"}},
+			expected:   "198.51.100.1",
+		},
+		{
+			name:       "trusted remote",
+			proxies:    trusted,
+			remoteAddr: "10.0.0.1:1000",
+			headers:    map[string][]string{"X-Forwarded-For": {"203.0.113.7"}},
+			expected:   "203.0.113.7",
+		},
+		{
+			name:       "spoofed chain",
+			proxies:    trusted,
+			remoteAddr: "10.0.0.1:1000",
+			headers:    map[string][]string{"X-Forwarded-For": {"1.1.1.1, 203.0.113.7, 10.0.0.2"}},
+			expected:   "203.0.113.7",
+		},
+		{
+			name:       "multiple headers",
+			proxies:    trusted,
+			remoteAddr: "10.0.0.1:1000",
+			headers:    map[string][]string{"X-Forwarded-For": {"1.1.1.1", "203.0.113.7"}},
+			expected:   "203.0.113.7",
+		},
+		{
+			name:       "invalid hop",
+			proxies:    trusted,
+			remoteAddr: "10.0.0.1:1000",
+			headers:    map[string][]string{"X-Forwarded-For": {"203.0.113.7, garbage"}},
+			expected:   "10.0.0.1",
+		},
+		{
+			name:       "real ip",
+			proxies:    trusted,
+			remoteAddr: "10.0.0.1:1000",
+			headers:    map[string][]string{"X-Real-Ip": {"2001:db8::7"}},
+			expected:   "2001:db8::7",
+		},
+		{
+			name:       "no headers",
+			proxies:    trusted,
+			remoteAddr: "10.0.0.1:1000",
+			expected:   "10.0.0.1",
+		},
+	}
+
+	for _, test := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/stats", nil)
+		r.RemoteAddr = test.remoteAddr
+		for name, values := range test.headers {
+			r.Header[name] = values
+		}
+
+		assert.Equal(t, test.expected, test.proxies.clientIP(r), test.name)
+	}
+}
+
+func TestStatsUseForwardedClientIP(t *testing.T) {
+	trusted, err := ParseTrustedProxies([]string{"10.0.0.0/8"})
+	require.NoError(t, err)
+
+	tracker := NewTracker(5, 0, 0, 0, 0)
+	for i := 0; i < maxBanScore; i++ {
+		tracker.increaseBanScore(&PlayerData{conn: newIPConn("203.0.113.7")}, false)
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/stats", nil)
+	r.RemoteAddr = "10.0.0.1:1000"
+	r.Header.Set("X-Forwarded-For", "203.0.113.7")
+	w := httptest.NewRecorder()
+
+	statsJSON(tracker, false, trusted)(w, r)
+
+	var stats TrackerStats
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &stats))
+	assert.True(t, stats.Banned)
+	assert.Equal(t, uint32(maxBanScore), stats.BanScore)
+}
+
+func TestForwardedConn(t *testing.T) {
+	conn := newForwardedConn(&fakeConn{}, "2001:db8::7")
+	assert.Equal(t, "2001:db8::7", getIP(conn))
+
+	unchanged := &fakeConn{}
+	assert.Equal(t, unchanged, newForwardedConn(unchanged, ""))
+}
@@ -0,0 +1,82 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"sync/atomic"
+
+	"github.com/ulule/limiter/v3"
+	"github.com/ulule/limiter/v3/drivers/middleware/stdlib"
+)
+
+// RateLimiter limits connections per IP. The rate can be changed while
+// listeners are using it without resetting the counts in its store.
+type RateLimiter struct {
+	mutex    sync.RWMutex
+	store    limiter.Store
+	limiter  *limiter.Limiter
+	handlers []*rateLimitedHandler
+}
+
+// rateLimitedHandler rate limits requests to a handler. Its middleware
+// is built once for every rate and swapped when the rate changes.
+type rateLimitedHandler struct {
+	h          http.Handler
+	keyGetter  stdlib.KeyGetter
+	middleware atomic.Value
+}
+
+// NewRateLimiter creates a rate limiter that keeps its counts in store.
+func NewRateLimiter(store limiter.Store, rate limiter.Rate) *RateLimiter {
+	return &RateLimiter{
+		store:   store,
+		limiter: limiter.New(store, rate),
+	}
+}
+
+// SetRate changes the rate of the limiter.
+func (r *RateLimiter) SetRate(rate limiter.Rate) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.limiter = limiter.New(r.store, rate)
+	for _, rh := range r.handlers {
+		rh.build(r.limiter)
+	}
+}
+
+// current returns the limiter for the current rate.
+func (r *RateLimiter) current() *limiter.Limiter {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	return r.limiter
+}
+
+// get increments the count for key and returns the limit context.
+func (r *RateLimiter) get(key string) (limiter.Context, error) {
+	return r.current().Get(context.Background(), key)
+}
+
+// handler rate limits requests to h by the IP keyGetter returns.
+func (r *RateLimiter) handler(h http.Handler, keyGetter stdlib.KeyGetter) http.Handler {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	rh := &rateLimitedHandler{h: h, keyGetter: keyGetter}
+	rh.build(r.limiter)
+	r.handlers = append(r.handlers, rh)
+
+	return rh
+}
+
+// build builds the middleware for l.
+func (rh *rateLimitedHandler) build(l *limiter.Limiter) {
+	rh.middleware.Store(stdlib.NewMiddleware(l, stdlib.WithKeyGetter(rh.keyGetter)).Handler(rh.h))
+}
+
+// ServeHTTP serves a request through the middleware for the current rate.
+func (rh *rateLimitedHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	rh.middleware.Load().(http.Handler).ServeHTTP(w, req)
+}
@@ -0,0 +1,49 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/ulule/limiter/v3"
+	"github.com/ulule/limiter/v3/drivers/store/memory"
+)
+
+func TestRateLimiterSetRate(t *testing.T) {
+	limit := NewRateLimiter(memory.NewStore(), limiter.Rate{Period: time.Minute, Limit: 2})
+
+	for i := 0; i < 2; i++ {
+		assert.True(t, allowConnection(newIPConn("8.8.8.8"), limit))
+	}
+	assert.False(t, allowConnection(newIPConn("8.8.8.8"), limit))
+
+	// raising the rate keeps the connections already counted
+	limit.SetRate(limiter.Rate{Period: time.Minute, Limit: 4})
+	assert.True(t, allowConnection(newIPConn("8.8.8.8"), limit))
+	assert.False(t, allowConnection(newIPConn("8.8.8.8"), limit))
+}
+
+func TestRateLimiterHandler(t *testing.T) {
+	limit := NewRateLimiter(memory.NewStore(), limiter.Rate{Period: time.Minute, Limit: 1})
+	h := limit.handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}), func(r *http.Request) string {
+		return "8.8.8.8"
+	})
+
+	get := func() int {
+		w := httptest.NewRecorder()
+		req, err := http.NewRequest("GET", "/", nil)
+		require.NoError(t, err)
+		h.ServeHTTP(w, req)
+
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusOK, get())
+	assert.Equal(t, http.StatusTooManyRequests, get())
+
+	limit.SetRate(limiter.Rate{Period: time.Minute, Limit: 3})
+	assert.Equal(t, http.StatusOK, get())
+}
@@ -2,16 +2,31 @@ package server
 
 import (
 	"errors"
-	"fmt"
 
 	"github.com/cashshuffle/cashshuffle/message"
 )
 
+var (
+	// errDuplicateKey is returned when a client registers with the
+	// verification key of a player the server already has.
+	errDuplicateKey = errors.New("verification key already registered")
+
+	// errInvalidRegistration is returned when a registration is missing
+	// its verification key or registration.
+	errInvalidRegistration = errors.New("registration failed")
+)
+
 // registerClient registers a new session.
 func (pi *packetInfo) registerClient() error {
+	if pi.wantsCapabilities() {
+		return pi.sendCapabilities()
+	}
+
 	var player *PlayerData
+	var version uint64
 	if len(pi.message.Packet) == 1 {
 		signed := pi.message.Packet[0]
+		version = signed.GetPacket().GetRegistration().GetVersion()
 
 		if signed.GetSignature() == nil {
 			p := signed.GetPacket()
@@ -20,8 +35,8 @@ func (pi *packetInfo) registerClient() error {
 			verificationKey := p.GetFromKey().GetKey()
 			player = pi.tracker.playerByVerificationKey(verificationKey)
 			if player != nil {
-				return fmt.Errorf("server already has a player "+
-					"with verification key %s", verificationKey)
+				logCommunication.Debugf("Duplicate verification key: %s\n", logKey(verificationKey))
+				return errDuplicateKey
 			}
 
 			if verificationKey != "" && registration != nil {
@@ -33,10 +48,17 @@ func (pi *packetInfo) registerClient() error {
 					shuffleType:     registration.GetType(),
 					version:         registration.GetVersion(),
 					isPassive:       false,
+					resumable:       hasFeature(p.GetCapabilities(), message.Feature_SESSION_RESUME),
 				}
-				pi.tracker.add(player)
+				if err := pi.tracker.add(player); err != nil {
+					if ferr := pi.registrationFailed(err, version); ferr != nil {
+						return ferr
+					}
 
-				err := pi.registrationSuccess(player)
+					return err
+				}
+
+				err := pi.registrationSuccess(player, p.GetCapabilities())
 				if err != nil {
 					pi.tracker.remove(pi.conn)
 				}
@@ -46,36 +68,84 @@ func (pi *packetInfo) registerClient() error {
 		}
 	}
 
-	if err := pi.registrationFailed(); err != nil {
+	if err := pi.registrationFailed(errInvalidRegistration, version); err != nil {
 		return err
 	}
 
-	return errors.New("registration failed")
+	return errInvalidRegistration
 }
 
-// registrationSuccess sends a registration success reply.
-func (pi *packetInfo) registrationSuccess(p *PlayerData) error {
-	m := message.Signed{
-		Packet: &message.Packet{
-			Session: p.sessionID,
-			Number:  p.number,
-		},
+// registrationSuccess sends a registration success reply. If the client
+// registered with its capabilities, the reply has those of the server.
+func (pi *packetInfo) registrationSuccess(p *PlayerData, offered *message.Capabilities) error {
+	reply := &message.Packet{
+		Session: p.sessionID,
+		Number:  p.number,
 	}
 
-	return writeMessage(pi.conn, []*message.Signed{&m})
+	if offered != nil {
+		reply.Capabilities = pi.tracker.capabilities(pi.conn, offered)
+	}
+
+	m, err := serverPacket(pi.tracker.serverIdentity(), p.version, p.sessionID, reply)
+	if err != nil {
+		return err
+	}
+
+	return writeMessage(pi.conn, []*message.Signed{m})
 }
 
-// registrationFailed sends a registration failed reply.
-func (pi *packetInfo) registrationFailed() error {
-	m := message.Signed{
-		Packet: &message.Packet{
-			Message: &message.Message{
-				Blame: &message.Blame{
-					Reason: message.Reason_INVALIDFORMAT,
-				},
+// registrationFailed sends a registration failed reply. The reason
+// is sent to the client as a string and as an error code. The reply is
+// signed if the client registered with a version that supports it, and
+// bound to the nonce of the request.
+func (pi *packetInfo) registrationFailed(reason error, version uint64) error {
+	m, err := serverPacket(pi.tracker.serverIdentity(), version, pi.nonce(), &message.Packet{
+		Message: &message.Message{
+			Str:       reason.Error(),
+			ErrorCode: errorCode(reason),
+			Blame: &message.Blame{
+				Reason: message.Reason_INVALIDFORMAT,
 			},
 		},
+	})
+	if err != nil {
+		return err
+	}
+
+	return writeMessage(pi.conn, []*message.Signed{m})
+}
+
+// errorCode returns the error code clients are sent for err.
+func errorCode(err error) message.ErrorCode {
+	switch err {
+	case errBanned:
+		return message.ErrorCode_BANNED
+	case errMaxConnections, errMaxConnectionsPerIP, errMaxConnectionsPerPrefix, errMaxPoolsPerIP:
+		return message.ErrorCode_LIMIT_REACHED
+	case errDraining:
+		return message.ErrorCode_DRAINING
+	case errCapabilitiesSent:
+		return message.ErrorCode_CAPABILITIES_ALREADY_SENT
+	case errNotHeld:
+		return message.ErrorCode_SESSION_NOT_HELD
+	case errResumeKey, errResumeSignature, errResumeStale:
+		return message.ErrorCode_INVALID_RESUME
+	case errReplayUnavailable:
+		return message.ErrorCode_REPLAY_UNAVAILABLE
+	default:
+		return message.ErrorCode_INVALID_REGISTRATION
+	}
+}
+
+// nonce returns the session of the request of a client that has no
+// session yet. Clients put a random nonce there, so that the signed reply
+// can not be replayed to another connection. Replies to requests without
+// one are signed but not replay protected.
+func (pi *packetInfo) nonce() []byte {
+	if len(pi.message.Packet) != 1 {
+		return nil
 	}
 
-	return writeMessage(pi.conn, []*message.Signed{&m})
+	return pi.message.Packet[0].GetPacket().GetSession()
 }
@@ -0,0 +1,316 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/cashshuffle/cashshuffle/message"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4/ecdsa"
+	"github.com/golang/protobuf/proto"
+)
+
+const (
+	// resumeContext is the signing context of resume requests.
+	resumeContext = "cashshuffle/resume/v1"
+
+	// resumeMaxSkew is how far the time of a resume request may be from
+	// the time of the server.
+	resumeMaxSkew = time.Minute
+
+	// maxReplayBroadcasts is the most broadcasts a pool keeps for
+	// players that resume.
+	maxReplayBroadcasts = 100
+
+	// maxReplayBytes is the most serialized bytes of broadcasts a pool
+	// keeps for players that resume.
+	maxReplayBytes = 1024 * 1024
+)
+
+var (
+	errNotHeld           = errors.New("no held session")
+	errResumeKey         = errors.New("invalid verification key")
+	errResumeSignature   = errors.New("invalid resume signature")
+	errResumeStale       = errors.New("stale resume request")
+	errReplayUnavailable = errors.New("missed broadcasts are no longer available")
+)
+
+// replayBuffer keeps the latest broadcasts of a pool, so that players
+// that resume can get the ones they missed. first is the number of
+// broadcasts that were dropped from the front of the buffer.
+type replayBuffer struct {
+	broadcasts [][]*message.Signed
+	sizes      []int
+	bytes      int
+	first      int
+}
+
+// add appends a broadcast, dropping the oldest ones once the buffer is
+// full.
+func (b *replayBuffer) add(msgs []*message.Signed) {
+	size := proto.Size(&message.Packets{Packet: msgs})
+
+	b.broadcasts = append(b.broadcasts, msgs)
+	b.sizes = append(b.sizes, size)
+	b.bytes += size
+
+	for len(b.broadcasts) > maxReplayBroadcasts || b.bytes > maxReplayBytes {
+		b.bytes -= b.sizes[0]
+		b.broadcasts = b.broadcasts[1:]
+		b.sizes = b.sizes[1:]
+		b.first++
+	}
+}
+
+// total returns the number of broadcasts ever added.
+func (b *replayBuffer) total() int {
+	return b.first + len(b.broadcasts)
+}
+
+// since returns the broadcasts after the first received ones, or false
+// if some of them were dropped.
+func (b *replayBuffer) since(received int) ([][]*message.Signed, bool) {
+	if received < b.first || received > b.total() {
+		return nil, false
+	}
+
+	return b.broadcasts[received-b.first:], true
+}
+
+// recordBroadcast keeps a broadcast for players that resume.
+func (pool *Pool) recordBroadcast(msgs []*message.Signed) {
+	pool.mutex.Lock()
+	defer pool.mutex.Unlock()
+
+	pool.replay.add(msgs)
+}
+
+// broadcastsSince returns the broadcasts a player that got received of
+// them missed, and the number of broadcasts so far.
+func (pool *Pool) broadcastsSince(received int) ([][]*message.Signed, int, bool) {
+	pool.mutex.RLock()
+	defer pool.mutex.RUnlock()
+
+	broadcasts, ok := pool.replay.since(received)
+
+	return broadcasts, pool.replay.total(), ok
+}
+
+// SetResumeGracePeriod sets how long the slot of a player that can
+// resume is held after it disconnects. A period of 0 turns resuming off.
+func (t *Tracker) SetResumeGracePeriod(d time.Duration) error {
+	if d < 0 {
+		return fmt.Errorf("invalid resume grace period: %s", d)
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.resumeGracePeriod = d
+
+	return nil
+}
+
+// hold keeps the slot of a disconnected player for the resume grace
+// period and returns true, or returns false if the player can not
+// resume. Only players of frozen pools that said they can resume are
+// held, since anyone else can simply register again.
+// This method assumes the caller is holding the mutex.
+func (t *Tracker) hold(p *PlayerData) bool {
+	if t.resumeGracePeriod == 0 || !p.resumable || p.kicked || !p.pool.IsFrozen() {
+		return false
+	}
+
+	p.holds++
+	hold := p.holds
+	time.AfterFunc(t.resumeGracePeriod, func() {
+		t.release(p, hold)
+	})
+
+	t.held[string(p.sessionID)] = p
+
+	logRound.Debugf("Holding slot of disconnected player: %s\n", p)
+
+	return true
+}
+
+// release removes a held player that did not resume in time. hold
+// tells apart holds of a player that resumed and disconnected again.
+func (t *Tracker) release(p *PlayerData, hold int) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if p.holds != hold || t.held[string(p.sessionID)] != p {
+		return
+	}
+
+	delete(t.held, string(p.sessionID))
+
+	logRound.Debugf("Releasing slot of player that did not resume: %s\n", p)
+
+	t.removePlayer(p)
+}
+
+// wantsResume returns true if a new connection asks to resume a session.
+func (pi *packetInfo) wantsResume() bool {
+	return len(pi.message.Packet) == 1 && pi.message.Packet[0].GetPacket().GetResume() != nil
+}
+
+// resumeSession gives the slot of a held player to a new connection.
+func (pi *packetInfo) resumeSession() error {
+	p, err := pi.tracker.resume(pi.conn, pi.message.Packet[0])
+	if err == nil {
+		return nil
+	}
+
+	var version uint64
+	if p != nil {
+		version = p.version
+	}
+
+	if ferr := pi.registrationFailed(err, version); ferr != nil {
+		return ferr
+	}
+
+	return err
+}
+
+// resume checks a resume request and moves the held player it names to
+// conn. The player is sent a reply with its number and round, followed
+// by the broadcasts it missed. Packets the server sent to the player
+// alone, such as the phase 1 announcement, are not replayed. Players
+// are only held in frozen pools, so the round in the reply tells the
+// client the announcement happened. The write mutex of the player is taken
+// before the tracker mutex is released, so that new broadcasts can not
+// overtake them. The held player is returned if there is one, even if
+// the request is refused.
+func (t *Tracker) resume(conn net.Conn, signed *message.Signed) (*PlayerData, error) {
+	p, reply, missed, err := t.resumePlayer(conn, signed)
+	if err != nil {
+		return p, err
+	}
+	defer p.writeMutex.Unlock()
+
+	if err := writeMessage(conn, []*message.Signed{reply}); err != nil {
+		return p, err
+	}
+
+	for _, msgs := range missed {
+		if err := writeMessage(conn, msgs); err != nil {
+			return p, err
+		}
+	}
+
+	return p, nil
+}
+
+// resumePlayer moves the held player named by a resume request to conn
+// and returns the reply and the broadcasts it missed. On success the
+// write mutex of the player is held, and the caller must unlock it once
+// it has written them.
+func (t *Tracker) resumePlayer(conn net.Conn, signed *message.Signed) (*PlayerData, *message.Signed, [][]*message.Signed, error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	packet := signed.GetPacket()
+	r := packet.GetResume()
+
+	p := t.held[string(packet.GetSession())]
+	if p == nil {
+		return nil, nil, nil, errNotHeld
+	}
+
+	if packet.GetFromKey().GetKey() != p.verificationKey {
+		return p, nil, nil, errResumeKey
+	}
+
+	if err := verifyResume(p, r, signed.GetSignature().GetSignature()); err != nil {
+		return p, nil, nil, err
+	}
+
+	missed, total, ok := p.pool.broadcastsSince(int(r.GetReceived()))
+	if !ok {
+		return p, nil, nil, errReplayUnavailable
+	}
+
+	reply, err := serverPacket(t.identity, p.version, p.sessionID, &message.Packet{
+		Session: p.sessionID,
+		Number:  p.number,
+		Round:   p.pool.Round(),
+		Resume:  &message.Resume{Received: uint32(total)},
+	})
+	if err != nil {
+		return p, nil, nil, err
+	}
+
+	p.holds++
+	p.lastResume = r.GetTimestamp()
+	delete(t.held, string(p.sessionID))
+
+	// every write to the player takes its write mutex, so taking it
+	// before the player is reachable queues new broadcasts behind the
+	// replay
+	p.writeMutex.Lock()
+
+	delete(t.connections, p.conn)
+	p.conn = conn
+	if info := t.openConnections[conn]; info != nil {
+		p.tor = info.tor
+	}
+	t.connections[conn] = p
+	t.verificationKeys[p.verificationKey] = conn
+
+	logRound.Debugf("Resumed player, replaying %d broadcasts: %s\n", len(missed), p)
+
+	return p, reply, missed, nil
+}
+
+// resumeSigned returns the bytes a resume request signs: the resume
+// context, a zero byte, and the session, verification key, received
+// broadcasts and time separated by newlines.
+func resumeSigned(session []byte, verificationKey string, r *message.Resume) []byte {
+	payload := fmt.Sprintf("%s\n%s\n%d\n%d", session, verificationKey, r.GetReceived(), r.GetTimestamp())
+
+	return signedBytes(resumeContext, []byte(payload))
+}
+
+// verifyResume checks that a resume request is recent, newer than the
+// last one of the player, and signed by the player's verification key,
+// which is a hex encoded secp256k1 public key. The signature is DER
+// encoded ECDSA over the SHA-256 of the signed bytes.
+func verifyResume(p *PlayerData, r *message.Resume, sig []byte) error {
+	now := time.Now().Unix()
+	ts := int64(r.GetTimestamp())
+	skew := int64(resumeMaxSkew / time.Second)
+
+	if ts < now-skew || ts > now+skew || r.GetTimestamp() <= p.lastResume {
+		return errResumeStale
+	}
+
+	b, err := hex.DecodeString(p.verificationKey)
+	if err != nil {
+		return errResumeSignature
+	}
+
+	key, err := secp256k1.ParsePubKey(b)
+	if err != nil {
+		return errResumeSignature
+	}
+
+	s, err := ecdsa.ParseDERSignature(sig)
+	if err != nil {
+		return errResumeSignature
+	}
+
+	hash := sha256.Sum256(resumeSigned(p.sessionID, p.verificationKey, r))
+	if !s.Verify(hash[:], key) {
+		return errResumeSignature
+	}
+
+	return nil
+}
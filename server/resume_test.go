@@ -0,0 +1,310 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/cashshuffle/cashshuffle/message"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4/ecdsa"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newResumableClient creates a client whose verification key is a
+// secp256k1 public key, so it can sign resume requests.
+func newResumableClient(h *testHarness) (*testClient, *secp256k1.PrivateKey) {
+	key, err := secp256k1.GeneratePrivateKey()
+	require.NoError(h.t, err)
+
+	c := newTestClient(h)
+	c.verificationKey = hex.EncodeToString(key.PubKey().SerializeCompressed())
+
+	return c, key
+}
+
+// registerResumable registers a connected client that can resume, and
+// consumes the registration reply.
+func (c *testClient) registerResumable() {
+	c.version = testVersion
+
+	err := writeMessage(c.conn, []*message.Signed{{
+		Packet: &message.Packet{
+			FromKey:      &message.VerificationKey{Key: c.verificationKey},
+			Registration: &message.Registration{Amount: testAmount, Version: testVersion},
+			Capabilities: &message.Capabilities{Features: []message.Feature{message.Feature_SESSION_RESUME}},
+		},
+	}})
+	require.NoError(c.h.t, err)
+
+	c.playerNum, c.session = c.h.WaitRegistered(c)
+}
+
+// sendResume connects the client again and asks to resume its session.
+func (c *testClient) sendResume(key *secp256k1.PrivateKey, received uint32, timestamp int64) {
+	c.Connect()
+
+	r := &message.Resume{Received: received, Timestamp: uint64(timestamp)}
+	hash := sha256.Sum256(resumeSigned(c.session, c.verificationKey, r))
+
+	err := writeMessage(c.conn, []*message.Signed{{
+		Packet: &message.Packet{
+			Session: c.session,
+			FromKey: &message.VerificationKey{Key: c.verificationKey},
+			Resume:  r,
+		},
+		Signature: &message.Signature{Signature: ecdsa.Sign(key, hash[:]).Serialize()},
+	}})
+	require.NoError(c.h.t, err)
+}
+
+// dropConnection closes the client side of the connection and waits for
+// the server to hold the slot.
+func (c *testClient) dropConnection() {
+	require.NoError(c.h.t, c.conn.Close())
+
+	require.Eventually(c.h.t, func() bool {
+		c.h.tracker.mutex.RLock()
+		defer c.h.tracker.mutex.RUnlock()
+
+		return c.h.tracker.held[string(c.session)] != nil
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestResumeSession(t *testing.T) {
+	h := newTestHarness(t, 2)
+	require.NoError(t, h.tracker.SetResumeGracePeriod(time.Minute))
+
+	caps := h.tracker.capabilities(nil, nil)
+	assert.Contains(t, caps.GetFeatures(), message.Feature_SESSION_RESUME)
+	assert.EqualValues(t, 60, caps.GetLimits().GetResumeGracePeriod())
+
+	first, key := newResumableClient(h)
+	first.Connect()
+	first.registerResumable()
+	h.WaitBroadcastNewPlayer(first, []*testClient{first})
+
+	second, _ := newResumableClient(h)
+	second.Connect()
+	second.registerResumable()
+
+	pool := []*testClient{first, second}
+	h.WaitBroadcastPhase1Announcement(pool)
+
+	first.BroadcastVerificationKey(pool)
+	first.dropConnection()
+
+	// the slot is kept while the player is away
+	h.AssertPoolStates([]testPoolState{{value: testAmount, version: testVersion, players: 2, isFull: true}}, true)
+
+	second.BroadcastVerificationKey([]*testClient{second})
+	second.BroadcastVerificationKey([]*testClient{second})
+
+	// a request from someone else is refused and the slot stays held
+	intruder, intruderKey := newResumableClient(h)
+	intruder.session = first.session
+	intruder.verificationKey = first.verificationKey
+	intruder.sendResume(intruderKey, 1, time.Now().Unix())
+	assert.Equal(t, errResumeSignature.Error(), intruder.popServerPacket().GetPacket().GetMessage().GetStr())
+	h.WaitNotConnected(intruder)
+
+	first.sendResume(key, 1, time.Now().Unix())
+
+	reply := first.popServerPacket().GetPacket()
+	assert.Equal(t, first.session, reply.GetSession())
+	assert.Equal(t, first.playerNum, reply.GetNumber())
+	assert.NotEmpty(t, reply.GetRound())
+	assert.EqualValues(t, 3, reply.GetResume().GetReceived())
+
+	// the broadcasts missed while away are replayed in order
+	h.WaitBroadcastVerificationKey(second.verificationKey, []*testClient{first, first})
+
+	// the player carries on from the new connection
+	first.BroadcastVerificationKey(pool)
+
+	h.tracker.mutex.RLock()
+	assert.Empty(t, h.tracker.held)
+	h.tracker.mutex.RUnlock()
+
+	// leaving for good once resuming is turned off
+	require.NoError(t, h.tracker.SetResumeGracePeriod(0))
+	for _, c := range pool {
+		c.Disconnect()
+	}
+	h.AssertServerBans([]testServerBanData{})
+	h.WaitEmptyInboxes(pool)
+}
+
+func TestResumeRefusals(t *testing.T) {
+	h := newTestHarness(t, 1)
+	require.NoError(t, h.tracker.SetResumeGracePeriod(time.Minute))
+
+	c, key := newResumableClient(h)
+	c.Connect()
+	c.registerResumable()
+	h.WaitBroadcastPhase1Announcement([]*testClient{c})
+	c.dropConnection()
+
+	for _, tc := range []struct {
+		name      string
+		received  uint32
+		timestamp int64
+		err       error
+		code      message.ErrorCode
+	}{
+		{"old", 0, time.Now().Add(-2 * resumeMaxSkew).Unix(), errResumeStale, message.ErrorCode_INVALID_RESUME},
+		{"future", 0, time.Now().Add(2 * resumeMaxSkew).Unix(), errResumeStale, message.ErrorCode_INVALID_RESUME},
+		{"unknown broadcasts", 5, time.Now().Unix(), errReplayUnavailable, message.ErrorCode_REPLAY_UNAVAILABLE},
+	} {
+		c.sendResume(key, tc.received, tc.timestamp)
+		failed := c.popServerPacket().GetPacket().GetMessage()
+		assert.Equal(t, tc.err.Error(), failed.GetStr(), tc.name)
+		assert.Equal(t, tc.code, failed.GetErrorCode(), tc.name)
+	}
+
+	// a request can not be used twice
+	now := time.Now().Unix()
+	c.sendResume(key, 0, now)
+	assert.NotNil(t, c.popServerPacket().GetPacket().GetResume())
+	c.dropConnection()
+
+	c.sendResume(key, 0, now)
+	assert.Equal(t, errResumeStale.Error(), c.popServerPacket().GetPacket().GetMessage().GetStr())
+
+	other, otherKey := newResumableClient(h)
+	other.session = []byte("unknown")
+	other.sendResume(otherKey, 0, now)
+	failed := other.popServerPacket().GetPacket().GetMessage()
+	assert.Equal(t, errNotHeld.Error(), failed.GetStr())
+	assert.Equal(t, message.ErrorCode_SESSION_NOT_HELD, failed.GetErrorCode())
+}
+
+// connectTCP connects the client to the server over TCP instead of a
+// pipe, so that the client can reset the connection.
+func (c *testClient) connectTCP() {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(c.h.t, err)
+	defer l.Close()
+
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			close(accepted)
+			return
+		}
+		accepted <- conn
+	}()
+
+	c.conn, err = net.Dial("tcp", l.Addr().String())
+	require.NoError(c.h.t, err)
+
+	c.remoteConn = <-accepted
+	require.NotNil(c.h.t, c.remoteConn)
+
+	c.inbox = newTestInbox(c.conn)
+	go handleConnection(c.remoteConn, c.h.packets, c.h.tracker, false)
+}
+
+func TestResumeAfterReset(t *testing.T) {
+	h := newTestHarness(t, 1)
+	require.NoError(t, h.tracker.SetResumeGracePeriod(time.Minute))
+
+	c, key := newResumableClient(h)
+	c.connectTCP()
+	c.registerResumable()
+	h.WaitBroadcastPhase1Announcement([]*testClient{c})
+
+	// a network switch resets the connection instead of closing it
+	require.NoError(t, c.conn.(*net.TCPConn).SetLinger(0))
+	c.dropConnection()
+
+	c.sendResume(key, 0, time.Now().Unix())
+	reply := c.popServerPacket().GetPacket()
+	assert.NotNil(t, reply.GetResume())
+	assert.NotEmpty(t, reply.GetRound())
+
+	h.AssertServerBans([]testServerBanData{})
+}
+
+func TestKickedPlayerCanNotResume(t *testing.T) {
+	h := newTestHarness(t, 1)
+	require.NoError(t, h.tracker.SetResumeGracePeriod(time.Minute))
+
+	c, key := newResumableClient(h)
+	c.Connect()
+	c.registerResumable()
+	h.WaitBroadcastPhase1Announcement([]*testClient{c})
+
+	// the server closes the connection of a player sending a bad packet
+	err := writeMessage(c.conn, []*message.Signed{{
+		Packet: &message.Packet{
+			Session: []byte("not the session"),
+			FromKey: &message.VerificationKey{Key: c.verificationKey},
+		},
+	}})
+	require.NoError(t, err)
+	h.WaitNotConnected(c)
+
+	h.tracker.mutex.RLock()
+	assert.Empty(t, h.tracker.held)
+	h.tracker.mutex.RUnlock()
+
+	c.sendResume(key, 0, time.Now().Unix())
+	assert.Equal(t, errNotHeld.Error(), c.popServerPacket().GetPacket().GetMessage().GetStr())
+}
+
+func TestHeldSlotIsReleased(t *testing.T) {
+	h := newTestHarness(t, 2)
+	require.NoError(t, h.tracker.SetResumeGracePeriod(50*time.Millisecond))
+
+	passive, _ := newResumableClient(h)
+	passive.Connect()
+	passive.registerResumable()
+	h.WaitBroadcastNewPlayer(passive, []*testClient{passive})
+
+	// a client that can not resume is removed right away
+	other := newTestClient(h)
+	other.Connect()
+	other.Register(testAmount, testVersion, []*testClient{passive, other}, true, true)
+	other.BroadcastVerificationKey([]*testClient{passive, other})
+	other.Disconnect()
+
+	passive.dropConnection()
+
+	// the passive player did not come back, so it gets the ban score
+	require.Eventually(t, func() bool {
+		return h.tracker.count() == 0
+	}, time.Second, 5*time.Millisecond)
+
+	h.AssertPoolStates([]testPoolState{}, true)
+	h.AssertServerBans([]testServerBanData{{client: passive, banData: banData{score: 1}}})
+}
+
+func TestReplayBuffer(t *testing.T) {
+	var b replayBuffer
+	for i := 0; i < maxReplayBroadcasts+5; i++ {
+		b.add([]*message.Signed{{Packet: &message.Packet{Number: uint32(i)}}})
+	}
+
+	assert.Equal(t, maxReplayBroadcasts+5, b.total())
+
+	_, ok := b.since(4)
+	assert.False(t, ok)
+
+	missed, ok := b.since(b.total() - 2)
+	require.True(t, ok)
+	require.Len(t, missed, 2)
+	assert.EqualValues(t, maxReplayBroadcasts+3, missed[0][0].GetPacket().GetNumber())
+
+	missed, ok = b.since(b.total())
+	assert.True(t, ok)
+	assert.Empty(t, missed)
+
+	_, ok = b.since(b.total() + 1)
+	assert.False(t, ok)
+}
@@ -0,0 +1,220 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/cashshuffle/cashshuffle/message"
+)
+
+const (
+	// maxRoundHistory is the most rounds listed on /v1/rounds.
+	maxRoundHistory = 1000
+
+	// maxStoredRounds is the most rounds that are remembered. Tier stats
+	// count completions from them, so it is well above what a server
+	// completes in tierHistoryLength. Rounds are also forgotten once they
+	// ended longer than tierHistoryLength ago.
+	maxStoredRounds = 100000
+)
+
+// errUnknownRound is returned for rounds that are not remembered.
+var errUnknownRound = errors.New("unknown round")
+
+// round outcomes
+const (
+	RoundRunning   = "running"
+	RoundCompleted = "completed"
+	RoundBanned    = "banned"
+	RoundAbandoned = "abandoned"
+)
+
+// RoundStats describes a round, which starts when a pool fills up and
+// ends when its last player leaves. A round completes if its players
+// got to the verification and submission phase and nobody in it was
+// banned. Rounds that end earlier without a ban are abandoned.
+type RoundStats struct {
+	ID      string `json:"id"`
+	Pool    int    `json:"pool"`
+	Amount  uint64 `json:"amount"`
+	Type    string `json:"type"`
+	Version uint64 `json:"version"`
+	Players int    `json:"players"`
+
+	// StartedAt is when the pool froze, in seconds since the epoch.
+	StartedAt int64 `json:"startedAt"`
+
+	// EndedAt is when the round ended, or 0 while it is running.
+	EndedAt int64  `json:"endedAt,omitempty"`
+	Outcome string `json:"outcome"`
+
+	tier poolTier
+}
+
+// RoundHistory lists recent rounds, newest first.
+type RoundHistory struct {
+	Rounds []RoundStats `json:"rounds"`
+}
+
+// roundHistorian is implemented by stats informers that remember rounds.
+type roundHistorian interface {
+	roundHistory() []RoundStats
+	servesRoundHistory() bool
+}
+
+// SetRoundHistory sets whether the round history is served on
+// /v1/rounds. Rounds are remembered either way, for the tier stats.
+func (t *Tracker) SetRoundHistory(serve bool) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.serveRoundHistory = serve
+}
+
+// servesRoundHistory returns true if the round history is served.
+func (t *Tracker) servesRoundHistory() bool {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+
+	return t.serveRoundHistory
+}
+
+// startRound remembers that a pool froze and started a round.
+// This method assumes the caller is holding the mutex.
+func (t *Tracker) startRound(pool *Pool) {
+	r := RoundStats{
+		ID:        pool.round,
+		Pool:      pool.num,
+		Amount:    pool.amount,
+		Type:      pool.shuffleType.String(),
+		Version:   pool.version,
+		Players:   pool.size,
+		StartedAt: pool.frozenAt.Unix(),
+		Outcome:   RoundRunning,
+		tier:      pool.tier(),
+	}
+
+	rounds := t.rounds
+	if len(rounds) >= maxStoredRounds {
+		rounds = rounds[len(rounds)-maxStoredRounds+1:]
+	}
+
+	t.rounds = append(rounds, r)
+
+	logRound.Debugf("Pool %d started round %s\n", pool.num, pool.round)
+}
+
+// endRound records the outcome of the round of a pool that emptied.
+// This method assumes the caller is holding the mutex.
+func (t *Tracker) endRound(pool *Pool) {
+	if pool.round == "" {
+		return
+	}
+
+	for i := len(t.rounds) - 1; i >= 0; i-- {
+		r := &t.rounds[i]
+		if r.ID != pool.round {
+			continue
+		}
+
+		r.EndedAt = time.Now().Unix()
+		r.Outcome = roundOutcome(pool)
+
+		logRound.Debugf("Round %s of pool %d ended: %s\n", r.ID, r.Pool, r.Outcome)
+		return
+	}
+}
+
+// roundOutcome returns the outcome of the round of a pool that emptied.
+func roundOutcome(pool *Pool) string {
+	if pool.firstBan != nil {
+		return RoundBanned
+	}
+
+	// Players that hit a problem after signing move on to blame, so only
+	// rounds that stopped at the last phase completed.
+	if pool.reachedPhase() == message.Phase_VERIFICATION_AND_SUBMISSION {
+		return RoundCompleted
+	}
+
+	return RoundAbandoned
+}
+
+// pruneRounds forgets rounds that ended too long ago to be reported.
+func (t *Tracker) pruneRounds() {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	cutoff := time.Now().Add(-tierHistoryLength).Unix()
+	rounds := t.rounds[:0]
+	for _, r := range t.rounds {
+		if r.EndedAt == 0 || r.EndedAt >= cutoff {
+			rounds = append(rounds, r)
+		}
+	}
+
+	// clear the tail so forgotten rounds can be collected
+	for i := len(rounds); i < len(t.rounds); i++ {
+		t.rounds[i] = RoundStats{}
+	}
+
+	t.rounds = rounds
+}
+
+// roundHistory returns the newest maxRoundHistory rounds, newest first.
+func (t *Tracker) roundHistory() []RoundStats {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+
+	n := len(t.rounds)
+	if n > maxRoundHistory {
+		n = maxRoundHistory
+	}
+
+	rounds := make([]RoundStats, 0, n)
+	for i := len(t.rounds) - 1; i >= len(t.rounds)-n; i-- {
+		rounds = append(rounds, t.rounds[i])
+	}
+
+	return rounds
+}
+
+// roundsV1 serves the round history on /v1/rounds, and a single round
+// on /v1/rounds/{id}. Both answer 404 unless the history is served.
+func roundsV1(si StatsInformer, rh roundHistorian) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !rh.servesRoundHistory() {
+			http.NotFound(w, r)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Set("Access-Control-Expose-Headers", signedStatsHeaders)
+
+		rounds := rh.roundHistory()
+
+		id := strings.TrimPrefix(r.URL.Path, "/v1/rounds")
+		if id == "" {
+			b, _ := json.Marshal(RoundHistory{Rounds: rounds})
+			writeStats(w, si, b)
+			return
+		}
+
+		id = strings.TrimPrefix(id, "/")
+		for _, round := range rounds {
+			if round.ID == id {
+				b, _ := json.Marshal(round)
+				writeStats(w, si, b)
+				return
+			}
+		}
+
+		w.WriteHeader(http.StatusNotFound)
+		b, _ := json.Marshal(map[string]string{"error": errUnknownRound.Error()})
+		w.Write(b)
+	}
+}
@@ -0,0 +1,171 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/cashshuffle/cashshuffle/message"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/ulule/limiter/v3"
+	"github.com/ulule/limiter/v3/drivers/store/memory"
+)
+
+// submitted are the broadcasts of players that got to the last phase.
+var submitted = []*message.Signed{{Packet: &message.Packet{Phase: message.Phase_VERIFICATION_AND_SUBMISSION}}}
+
+func TestRoundHistory(t *testing.T) {
+	tracker := NewTracker(basicPoolSize, 1337, 1338, 0, 0)
+
+	mux := http.NewServeMux()
+	handleStats(mux, tracker, false, NewRateLimiter(memory.NewStore(), limiter.Rate{Period: time.Minute, Limit: 100}), nil)
+
+	getRounds := func() []RoundStats {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest("GET", "/v1/rounds", nil))
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var rh RoundHistory
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &rh))
+		return rh.Rounds
+	}
+
+	// the history is only served when enabled
+	for _, path := range []string{"/v1/rounds", "/v1/rounds/unknown"} {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		assert.Equal(t, http.StatusNotFound, w.Code, path)
+	}
+
+	tracker.SetRoundHistory(true)
+	assert.Empty(t, getRounds())
+
+	// fill two pools of the same amount, one after the other
+	var players []*PlayerData
+	for i := 0; i < 2*basicPoolSize; i++ {
+		p := &PlayerData{
+			conn:            newIPConn(fmt.Sprintf("8.8.8.%d", i)),
+			verificationKey: fmt.Sprintf("vk%d", i),
+			amount:          testAmount,
+			version:         testVersion,
+		}
+		require.NoError(t, tracker.add(p))
+		players = append(players, p)
+	}
+
+	first, second := players[0].pool, players[basicPoolSize].pool
+	require.NotEmpty(t, first.Round())
+	require.NotEmpty(t, second.Round())
+	assert.NotEqual(t, first.Round(), second.Round())
+
+	rounds := getRounds()
+	require.Len(t, rounds, 2)
+	assert.Equal(t, second.Round(), rounds[0].ID)
+	assert.Equal(t, RoundStats{
+		ID:        first.Round(),
+		Pool:      first.num,
+		Amount:    testAmount,
+		Type:      "DEFAULT",
+		Version:   testVersion,
+		Players:   basicPoolSize,
+		StartedAt: first.frozenAt.Unix(),
+		Outcome:   RoundRunning,
+	}, rounds[1])
+
+	// the stats of full pools carry their round
+	for _, ps := range tracker.Stats("", false).Pools {
+		assert.Contains(t, []string{first.Round(), second.Round()}, ps.Round)
+	}
+
+	getRound := func(id string) RoundStats {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest("GET", "/v1/rounds/"+id, nil))
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var round RoundStats
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &round))
+		return round
+	}
+
+	// a round without bans that got to the last phase completes once
+	// everyone leaves
+	first.recordPhase(submitted)
+	for _, p := range players[:basicPoolSize] {
+		tracker.remove(p.conn)
+	}
+
+	round := getRound(first.Round())
+	assert.Equal(t, RoundCompleted, round.Outcome)
+	assert.NotZero(t, round.EndedAt)
+
+	// a round its players leave earlier is abandoned
+	second.recordPhase([]*message.Signed{{Packet: &message.Packet{Phase: message.Phase_SHUFFLE}}})
+	for _, p := range players[basicPoolSize:] {
+		tracker.remove(p.conn)
+	}
+
+	round = getRound(second.Round())
+	assert.Equal(t, RoundAbandoned, round.Outcome)
+	assert.NotZero(t, round.EndedAt)
+
+	// abandoned rounds do not count as completions
+	tiers := tracker.Stats("", false).Tiers
+	require.Len(t, tiers, 1)
+	assert.Equal(t, 1, tiers[0].Completed24h)
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", "/v1/rounds/unknown", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.JSONEq(t, `{"error": "unknown round"}`, w.Body.String())
+}
+
+func TestRoundHistoryIsBounded(t *testing.T) {
+	tracker := NewTracker(1, 1337, 1338, 0, 0)
+
+	for i := 0; i < maxRoundHistory+10; i++ {
+		p := &PlayerData{
+			conn:            newIPConn("8.8.8.8"),
+			verificationKey: fmt.Sprintf("vk%d", i),
+			amount:          testAmount,
+		}
+		require.NoError(t, tracker.add(p))
+		p.pool.recordPhase(submitted)
+		tracker.remove(p.conn)
+	}
+
+	assert.Len(t, tracker.roundHistory(), maxRoundHistory)
+
+	// completions are counted from every remembered round
+	tiers := tracker.Stats("", false).Tiers
+	require.Len(t, tiers, 1)
+	assert.Equal(t, maxRoundHistory+10, tiers[0].Completed24h)
+}
+
+func TestAnnouncementCarriesRound(t *testing.T) {
+	h := newTestHarness(t, 2)
+
+	first := newTestClient(h)
+	first.Connect()
+	first.sendRegistration(testVersion)
+	first.popServerPacket()
+	first.popServerPacket()
+
+	second := newTestClient(h)
+	second.Connect()
+	second.sendRegistration(testVersion)
+	second.popServerPacket()
+
+	round := h.tracker.playerByConnection(first.remoteConn).pool.Round()
+	require.NotEmpty(t, round)
+
+	for _, c := range []*testClient{first, second} {
+		announcement := c.popServerPacket().GetPacket()
+		assert.Equal(t, message.Phase_ANNOUNCEMENT, announcement.GetPhase())
+		assert.Equal(t, round, announcement.GetRound())
+	}
+}
@@ -0,0 +1,72 @@
+package server
+
+import (
+	"fmt"
+)
+
+// PoolSeparation controls whether players that share a network
+// address may sit in the same pool. Tor players are never separated
+// since they all share the address of the Tor daemon.
+type PoolSeparation int
+
+const (
+	// PoolSeparationNone allows players from the same address to share a pool.
+	PoolSeparationNone PoolSeparation = iota
+
+	// PoolSeparationIP refuses players from the same IP in a pool.
+	PoolSeparationIP
+
+	// PoolSeparationPrefix refuses players from the same IP prefix in a pool.
+	PoolSeparationPrefix
+)
+
+// ParsePoolSeparation parses "none", "ip" or "prefix".
+func ParsePoolSeparation(s string) (PoolSeparation, error) {
+	switch s {
+	case "none":
+		return PoolSeparationNone, nil
+	case "ip":
+		return PoolSeparationIP, nil
+	case "prefix":
+		return PoolSeparationPrefix, nil
+	}
+
+	return PoolSeparationNone, fmt.Errorf("invalid pool separation: %s", s)
+}
+
+// SetPoolSeparation sets the rule for players sharing a pool.
+func (t *Tracker) SetPoolSeparation(separation PoolSeparation) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.poolSeparation = separation
+}
+
+// separatedFromPool returns true if the player shares an address with
+// a clearnet player in the pool.
+// This method assumes the caller is holding the mutex.
+func (t *Tracker) separatedFromPool(p *PlayerData, pool *Pool) bool {
+	if p.tor || t.poolSeparation == PoolSeparationNone {
+		return false
+	}
+
+	key := t.separationKey(p)
+	for _, other := range pool.players {
+		if !other.tor && t.separationKey(other) == key {
+			return true
+		}
+	}
+
+	return false
+}
+
+// separationKey returns the address players are separated by.
+// This method assumes the caller is holding the mutex.
+func (t *Tracker) separationKey(p *PlayerData) string {
+	ip := getIP(p.conn)
+	if t.poolSeparation == PoolSeparationPrefix {
+		return t.ipKey(ip)
+	}
+
+	return ipKey(ip, maxIPv4PrefixLength, maxIPv6PrefixLength)
+}
@@ -0,0 +1,81 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePoolSeparation(t *testing.T) {
+	for s, expected := range map[string]PoolSeparation{
+		"none":   PoolSeparationNone,
+		"ip":     PoolSeparationIP,
+		"prefix": PoolSeparationPrefix,
+	} {
+		separation, err := ParsePoolSeparation(s)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, separation)
+	}
+
+	_, err := ParsePoolSeparation("subnet")
+	assert.Error(t, err)
+}
+
+func TestPoolSeparation(t *testing.T) {
+	tests := []struct {
+		separation PoolSeparation
+		ips        []string
+		tor        bool
+		pools      int
+	}{
+		{PoolSeparationNone, []string{"8.8.8.8", "8.8.8.8", "8.8.8.8"}, false, 1},
+		{PoolSeparationIP, []string{"8.8.8.8", "8.8.8.8", "8.8.8.8"}, false, 3},
+		{PoolSeparationIP, []string{"8.8.8.8", "::ffff:8.8.8.8", "8.8.8.9"}, false, 2},
+		{PoolSeparationIP, []string{"2001:db8::1", "2001:db8::2", "2001:db8::3"}, false, 1},
+		{PoolSeparationPrefix, []string{"2001:db8::1", "2001:db8::2", "2001:db8:1::1"}, false, 2},
+		{PoolSeparationPrefix, []string{"8.8.8.8", "8.8.8.9", "8.8.4.4"}, false, 2},
+		{PoolSeparationPrefix, []string{"127.0.0.1", "127.0.0.1", "127.0.0.1"}, true, 1},
+	}
+
+	for _, test := range tests {
+		tracker := NewTracker(5, 0, 0, 0, 0)
+		require.NoError(t, tracker.SetPrefixLengths(24, 64))
+		tracker.SetPoolSeparation(test.separation)
+
+		for i, ip := range test.ips {
+			conn := newIPConn(ip)
+			require.NoError(t, tracker.open(conn, test.tor))
+			require.NoError(t, tracker.add(&PlayerData{
+				conn:            conn,
+				verificationKey: string(rune('a' + i)),
+				blamedBy:        make(map[string]interface{}),
+			}))
+		}
+
+		assert.Len(t, tracker.pools, test.pools, "%v %v", test.separation, test.ips)
+	}
+}
+
+func TestPoolSeparationIgnoresTorPlayers(t *testing.T) {
+	tracker := NewTracker(5, 0, 0, 0, 0)
+	tracker.SetPoolSeparation(PoolSeparationIP)
+
+	add := func(vk string, ip string, tor bool) {
+		conn := newIPConn(ip)
+		require.NoError(t, tracker.open(conn, tor))
+		require.NoError(t, tracker.add(&PlayerData{
+			conn:            conn,
+			verificationKey: vk,
+			blamedBy:        make(map[string]interface{}),
+		}))
+	}
+
+	// a clearnet player on the tor bind address does not collide with
+	// tor players
+	add("tor1", "127.0.0.1", true)
+	add("clearnet", "127.0.0.1", false)
+	add("tor2", "127.0.0.1", true)
+
+	assert.Len(t, tracker.pools, 1)
+}
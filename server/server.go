@@ -7,35 +7,22 @@ import (
 	"net/http"
 	"time"
 
-	"github.com/ulule/limiter/v3"
-	"github.com/ulule/limiter/v3/drivers/middleware/stdlib"
 	"golang.org/x/crypto/acme/autocert"
-	"golang.org/x/net/websocket"
-
-	log "github.com/sirupsen/logrus"
 )
 
-func init() {
-	log.SetFormatter(&log.TextFormatter{
-		ForceColors: true, // much more readable format for normal use
-	})
-}
-
-// Start brings up the TCP server.
-func Start(ip string, port int, cert string, key string, debug bool, t *Tracker, m *autocert.Manager, tor bool, limit *limiter.Limiter) (err error) {
+// Start brings up the TCP server. If proxyProtocol is not empty, the
+// real client address is read from a PROXY protocol header sent by
+// connections from those IPs or CIDRs.
+func Start(ip string, port int, cert string, key string, t *Tracker, h *Health, m *autocert.Manager, tor bool, limit *RateLimiter, proxyProtocol []string) (err error) {
 	var listener net.Listener
 
-	if debug {
-		log.SetLevel(log.DebugLevel)
+	listener, err = listen(ip, port, proxyProtocol, h)
+	if err != nil {
+		return err
 	}
 
 	if tlsEnabled(cert, key, m) {
-		listener, err = createTLSListener(ip, port, cert, key, m)
-		if err != nil {
-			return err
-		}
-	} else {
-		listener, err = net.Listen("tcp", fmt.Sprintf("%s:%d", ip, port))
+		listener, err = createTLSListener(listener, cert, key, m)
 		if err != nil {
 			return err
 		}
@@ -51,92 +38,112 @@ func Start(ip string, port int, cert string, key string, debug bool, t *Tracker,
 		torStr = "Tor"
 	}
 
-	log.Infof(logListener+"%sShuffle Listening on TCP %s:%d (pool size: %d)\n", torStr, ip, port, t.poolSize)
+	logListener.Infof("%sShuffle Listening on TCP %s:%d (pool size: %d)\n", torStr, ip, port, t.poolSize)
+	if tor && len(proxyProtocol) == 0 {
+		logListener.Warn("Tor circuit IDs are not trusted, so Tor players can not be banned\n")
+	}
+
 	for {
 		conn, err := listener.Accept()
 		if err != nil {
 			continue
 		}
 
-		ip := getIP(conn)
+		// Reading the client address may block on a PROXY protocol
+		// header, so the rate limit is checked off the accept loop.
+		go func() {
+			if !allowConnection(conn, limit) {
+				conn.Close()
+				return
+			}
 
-		context, err := limit.Get(nil, ip)
-		if err != nil {
-			log.Debugf(logListener+"Unable to get connection limit: %s\n", err)
-			conn.Close()
-			continue
-		}
+			handleConnection(conn, packetInfoChan, t, tor)
+		}()
+	}
+}
 
-		if context.Reached {
-			log.Debugf(logListener+"Rate limit exceeded by %s\n", ip)
-			conn.Close()
-			continue
-		}
+// StartWebsocket brings up the websocket server. PROXY protocol headers
+// are read from proxyProtocol sources and forwarded headers are only
+// honored for requests from trustedProxies.
+func StartWebsocket(ip string, port int, cert string, key string, t *Tracker, h *Health, m *autocert.Manager, tor bool, limit *RateLimiter, proxyProtocol []string, trustedProxies TrustedProxies, opts WebsocketOptions) (err error) {
+	mux := http.NewServeMux()
+	mux.Handle("/", limit.handler(websocketHandler(t, tor, trustedProxies, opts), trustedProxies.clientIP))
+
+	srv := newWebsocketServer(fmt.Sprintf("%s:%d", ip, port), mux)
+
+	torStr := ""
+	if tor {
+		torStr = "Tor"
+	}
 
-		go handleConnection(conn, packetInfoChan, t)
+	listener, err := listen(ip, port, proxyProtocol, h)
+	if err != nil {
+		return err
 	}
+
+	logListener.Infof("%sShuffle Listening via Websockets on %s:%d\n", torStr, ip, port)
+
+	return serveHTTP(srv, listener, cert, key, m)
 }
 
-// StartWebsocket brings up the websocket server.
-func StartWebsocket(ip string, port int, cert string, key string, debug bool, t *Tracker, m *autocert.Manager, tor bool, limit *limiter.Limiter) (err error) {
+// websocketHandler upgrades requests to websocket shuffle connections.
+func websocketHandler(t *Tracker, tor bool, trustedProxies TrustedProxies, opts WebsocketOptions) http.Handler {
 	packetInfoChan := make(chan *packetInfo)
 	go startPacketInfoChan(packetInfoChan)
 
-	var handleConnectionFunc = func(ws *websocket.Conn) {
-		// Need to enforce binary type. Text framing won't work.
-		ws.PayloadType = websocket.BinaryFrame
+	upgrader := newUpgrader(opts)
 
-		handleConnection(ws, packetInfoChan, t)
-	}
-
-	portString := fmt.Sprintf("%s:%d", ip, port)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ws, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			logListener.Debugf("Websocket upgrade failed for %s: %s\n", logIP(trustedProxies.clientIP(r)), logError(err))
+			return
+		}
 
-	mux := http.NewServeMux()
-	middleware := stdlib.NewMiddleware(limit)
-	mux.Handle("/", middleware.Handler(websocket.Handler(handleConnectionFunc)))
+		handleConnection(newForwardedConn(newWSConn(ws, opts), trustedProxies.clientIP(r)), packetInfoChan, t, tor)
+	})
+}
 
-	srv := &http.Server{
-		Addr:         portString,
-		Handler:      mux,
-		TLSConfig:    &tls.Config{},
+// newWebsocketServer creates an HTTP server for websocket connections.
+// HTTP/2 is turned off since websockets need HTTP/1.1.
+func newWebsocketServer(addr string, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:         addr,
+		Handler:      handler,
 		TLSNextProto: make(map[string]func(*http.Server, *tls.Conn, http.Handler), 0),
 		ReadTimeout:  10 * time.Second,
 		WriteTimeout: 15 * time.Second,
 		IdleTimeout:  deadline,
 	}
+}
 
-	if m != nil {
-		srv.TLSConfig.GetCertificate = m.GetCertificate
+// serveHTTP serves srv on listener, with TLS if it is enabled.
+func serveHTTP(srv *http.Server, listener net.Listener, cert string, key string, m *autocert.Manager) error {
+	if !tlsEnabled(cert, key, m) {
+		return srv.Serve(listener)
 	}
 
-	torStr := ""
-	if tor {
-		torStr = "Tor"
+	var err error
+	srv.TLSConfig, err = createTLSConfig(cert, key, m)
+	if err != nil {
+		return err
 	}
 
-	log.Infof(logListener+"%sShuffle Listening via Websockets on %s:%d\n", torStr, ip, port)
-
-	if tlsEnabled(cert, key, m) {
-		err = srv.ListenAndServeTLS(cert, key)
-		if err != nil {
-			return err
-		}
-	} else {
-		err = srv.ListenAndServe()
-		if err != nil {
-			return err
-		}
-	}
-
-	return nil
+	return srv.ServeTLS(listener, "", "")
 }
 
-func handleConnection(conn net.Conn, c chan *packetInfo, tracker *Tracker) {
+func handleConnection(conn net.Conn, c chan *packetInfo, tracker *Tracker, tor bool) {
 	defer conn.Close()
 
+	if err := tracker.open(conn, tor); err != nil {
+		logListener.Debugf("Rejecting connection from %s: %s\n", logIP(getIP(conn)), err)
+		return
+	}
+	defer tracker.close(conn)
+
 	// They just connected, set the deadline to prevent leaked connections.
 	if err := conn.SetDeadline(time.Now().Add(connectDeadline)); err != nil {
-		log.Debugf(logCommunication+"Received message but unable to extend deadline: %s\n", err)
+		logCommunication.Debugf("Received message but unable to extend deadline: %s\n", logError(err))
 	}
 
 	if !tracker.bannedByServer(conn) {
@@ -144,6 +151,24 @@ func handleConnection(conn net.Conn, c chan *packetInfo, tracker *Tracker) {
 	}
 }
 
+// allowConnection returns true if the connection is within the rate limit.
+func allowConnection(conn net.Conn, limit *RateLimiter) bool {
+	ip := getIP(conn)
+
+	context, err := limit.get(ip)
+	if err != nil {
+		logListener.Debugf("Unable to get connection limit: %s\n", logError(err))
+		return false
+	}
+
+	if context.Reached {
+		logListener.Debugf("Rate limit exceeded by %s\n", logIP(ip))
+		return false
+	}
+
+	return true
+}
+
 func getIP(conn net.Conn) string {
 	ip, _, _ := net.SplitHostPort(conn.RemoteAddr().String())
 	return ip
@@ -0,0 +1,152 @@
+package server
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// signingKeyType is the PEM block type of saved signing keys.
+const signingKeyType = "PRIVATE KEY"
+
+var (
+	errBadSignature  = errors.New("bad signature")
+	errBadPayload    = errors.New("payload is not compact JSON")
+	errBadSigningKey = errors.New("bad public key")
+	errNotEd25519Key = errors.New("signing key is not an ed25519 key")
+	errNoSigningKey  = errors.New("no signing key found")
+)
+
+// SignedMessage is a JSON payload signed with a server's ed25519 key. The
+// signature covers a context string naming the kind of message, a zero
+// byte and the payload exactly as it appears in the message, so the
+// payload is kept compact and is never re-encoded.
+type SignedMessage struct {
+	Payload   json.RawMessage `json:"payload"`
+	PublicKey string          `json:"publicKey"`
+	Signature string          `json:"signature"`
+}
+
+// LoadSigningKey reads the server's ed25519 key from keyPath, or creates
+// and saves one there on first use so the server keeps its identity
+// across restarts.
+func LoadSigningKey(keyPath string) (ed25519.PrivateKey, error) {
+	b, err := ioutil.ReadFile(keyPath)
+	if os.IsNotExist(err) {
+		return createSigningKey(keyPath)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(b)
+	if block == nil || block.Type != signingKeyType {
+		return nil, fmt.Errorf("%s: %s", keyPath, errNoSigningKey)
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", keyPath, err)
+	}
+
+	k, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%s: %s", keyPath, errNotEd25519Key)
+	}
+
+	return k, nil
+}
+
+// createSigningKey creates a signing key and saves it so that only the
+// owner can read it.
+func createSigningKey(keyPath string) (ed25519.PrivateKey, error) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(keyPath), 0700); err != nil {
+		return nil, err
+	}
+
+	b := pem.EncodeToMemory(&pem.Block{Type: signingKeyType, Bytes: der})
+	if err := ioutil.WriteFile(keyPath, b, 0600); err != nil {
+		return nil, err
+	}
+
+	return key, nil
+}
+
+// encodePublicKey returns the form public keys take in signed messages.
+func encodePublicKey(key ed25519.PublicKey) string {
+	return base64.StdEncoding.EncodeToString(key)
+}
+
+// decodePublicKey parses a public key from a signed message.
+func decodePublicKey(s string) (ed25519.PublicKey, error) {
+	b, err := base64.StdEncoding.DecodeString(s)
+	if err != nil || len(b) != ed25519.PublicKeySize {
+		return nil, errBadSigningKey
+	}
+
+	return ed25519.PublicKey(b), nil
+}
+
+// signedBytes returns the bytes a signature covers.
+func signedBytes(context string, payload []byte) []byte {
+	b := make([]byte, 0, len(context)+1+len(payload))
+	b = append(b, context...)
+	b = append(b, 0)
+
+	return append(b, payload...)
+}
+
+// signMessage encodes v and signs it for context.
+func signMessage(key ed25519.PrivateKey, context string, v interface{}) (*SignedMessage, error) {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SignedMessage{
+		Payload:   payload,
+		PublicKey: encodePublicKey(key.Public().(ed25519.PublicKey)),
+		Signature: base64.StdEncoding.EncodeToString(ed25519.Sign(key, signedBytes(context, payload))),
+	}, nil
+}
+
+// verify checks the signature of the message for context and returns the
+// key that signed it. Payloads that would change when the message is
+// encoded again are rejected, so verified messages can be passed on.
+func (m *SignedMessage) verify(context string) (ed25519.PublicKey, error) {
+	key, err := decodePublicKey(m.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	encoded, err := json.Marshal(m.Payload)
+	if err != nil || !bytes.Equal(encoded, m.Payload) {
+		return nil, errBadPayload
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(m.Signature)
+	if err != nil || !ed25519.Verify(key, signedBytes(context, m.Payload), sig) {
+		return nil, errBadSignature
+	}
+
+	return key, nil
+}
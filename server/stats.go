@@ -7,39 +7,56 @@ type StatsInformer interface {
 
 // TrackerStats represents a snapshot of the trackers statistics
 type TrackerStats struct {
-	BanScore             uint32      `json:"banScore"`
-	Banned               bool        `json:"banned"`
-	Connections          int         `json:"connections"`
-	PoolSize             int         `json:"poolSize"`
-	Pools                []PoolStats `json:"pools"`
-	ShufflePort          int         `json:"shufflePort"`
-	ShuffleWebSocketPort int         `json:"shuffleWebSocketPort"`
+	BanScore             uint32         `json:"banScore"`
+	Banned               bool           `json:"banned"`
+	Connections          int            `json:"connections"`
+	PoolSize             int            `json:"poolSize"`
+	Pools                []PoolStats    `json:"pools"`
+	ShufflePort          int            `json:"shufflePort"`
+	ShuffleWebSocketPort int            `json:"shuffleWebSocketPort"`
+	Rejections           RejectionStats `json:"rejections"`
+	OnionAddress         string         `json:"onionAddress,omitempty"`
+	Draining             bool           `json:"draining"`
+	Tiers                []TierStats    `json:"tiers"`
+	PublicKey            string         `json:"publicKey,omitempty"`
+	SignedPacketsVersion uint64         `json:"signedPacketsVersion,omitempty"`
+	Versions             []uint64       `json:"versions,omitempty"`
 }
 
 // PoolStats represents the stats for a particular pool
 type PoolStats struct {
+	ID      int    `json:"id"`
 	Members int    `json:"members"`
 	Amount  uint64 `json:"amount"`
 	Type    string `json:"type"`
 	Full    bool   `json:"full"`
 	Version uint64 `json:"version"`
+
+	// CreatedAt is when the pool was created, in seconds since the epoch.
+	CreatedAt int64 `json:"createdAt"`
+
+	// SinceLastJoin is the number of seconds since a player last joined.
+	SinceLastJoin int64 `json:"sinceLastJoin"`
+
+	// Round is the ID of the round of a full pool.
+	Round string `json:"round,omitempty"`
 }
 
 // Stats returns the tracker stats.
 func (t *Tracker) Stats(ip string, tor bool) *TrackerStats {
-	t.mutex.RLock()
-	defer t.mutex.RUnlock()
+	banScore, _ := t.lookupBanScore(func() (string, bool) {
+		if tor {
+			if circuitKey, ok := torCircuitBanKey(ip); ok {
+				return circuitKey, true
+			}
+		}
 
-	banned := false
-	var banScore uint32
+		return t.ipKey(ip), true
+	})
+	banned := banScore >= maxBanScore
 
-	data := t.banData[ip]
-	if data != nil {
-		banScore = data.score
-		if data.score >= maxBanScore {
-			banned = true
-		}
-	}
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
 
 	sp := t.shufflePort
 	if tor {
@@ -56,20 +73,24 @@ func (t *Tracker) Stats(ip string, tor bool) *TrackerStats {
 		Banned:               banned,
 		Connections:          len(t.connections),
 		PoolSize:             t.poolSize,
+		Draining:             t.health.isDraining(),
 		Pools:                make([]PoolStats, 0),
 		ShufflePort:          sp,
 		ShuffleWebSocketPort: wssp,
+		Rejections:           t.rejections,
+		OnionAddress:         t.onionAddress,
+		Versions:             t.versions,
 	}
 
 	for _, p := range t.pools {
-		ps := PoolStats{
-			Members: p.PlayerCount(),
-			Amount:  p.amount,
-			Type:    p.shuffleType.String(),
-			Full:    p.IsFrozen(),
-			Version: p.version,
-		}
-		ts.Pools = append(ts.Pools, ps)
+		ts.Pools = append(ts.Pools, poolStats(p))
+	}
+
+	ts.Tiers = t.tierStats()
+
+	if t.identity != nil {
+		ts.PublicKey = t.identity.PublicKey()
+		ts.SignedPacketsVersion = SignedPacketsVersion
 	}
 
 	return ts
@@ -1,27 +1,21 @@
 package server
 
 import (
-	"crypto/tls"
 	"encoding/json"
 	"fmt"
-	"net"
 	"net/http"
 	"time"
 
-	"github.com/ulule/limiter/v3"
-	"github.com/ulule/limiter/v3/drivers/middleware/stdlib"
 	"golang.org/x/crypto/acme/autocert"
-
-	log "github.com/sirupsen/logrus"
 )
 
-// StartStatsServer creates a new server to serve stats
-func StartStatsServer(ip string, port int, cert string, key string, si StatsInformer, m *autocert.Manager, tor bool, limit *limiter.Limiter) error {
+// StartStatsServer creates a new server to serve stats. PROXY protocol
+// headers are read from proxyProtocol sources and forwarded headers are
+// only honored for requests from trustedProxies.
+func StartStatsServer(ip string, port int, cert string, key string, si StatsInformer, h *Health, m *autocert.Manager, tor bool, limit *RateLimiter, proxyProtocol []string, trustedProxies TrustedProxies) error {
 	mux := http.NewServeMux()
-	middleware := stdlib.NewMiddleware(limit)
-	statsJSONHandler := http.HandlerFunc(statsJSON(si, tor))
-	mux.Handle("/stats", middleware.Handler(statsJSONHandler))
-	s := newStatsServer(fmt.Sprintf("%s:%d", ip, port), mux, m)
+	handleAPI(mux, si, h, tor, limit, trustedProxies)
+	s := newStatsServer(fmt.Sprintf("%s:%d", ip, port), mux)
 	isTLS := tlsEnabled(cert, key, m)
 
 	torStr := ""
@@ -29,37 +23,61 @@ func StartStatsServer(ip string, port int, cert string, key string, si StatsInfo
 		torStr = "Tor"
 	}
 
-	log.Infof(logListener+"%sStats Listening on TCP %s:%d (tls: %v)\n", torStr, ip, port, isTLS)
-	if isTLS {
-		return s.ListenAndServeTLS(cert, key)
+	listener, err := listen(ip, port, proxyProtocol, h)
+	if err != nil {
+		return err
 	}
-	return s.ListenAndServe()
+
+	logListener.Infof("%sStats Listening on TCP %s:%d (tls: %v)\n", torStr, ip, port, isTLS)
+
+	return serveHTTP(s, listener, cert, key, m)
 }
 
-func statsJSON(si StatsInformer, tor bool) func(http.ResponseWriter, *http.Request) {
+// handleAPI registers the stats, federation and health endpoints on mux.
+func handleAPI(mux *http.ServeMux, si StatsInformer, h *Health, tor bool, limit *RateLimiter, trustedProxies TrustedProxies) {
+	handleStats(mux, si, tor, limit, trustedProxies)
+	handleFederation(mux, limit, trustedProxies)
+	handleHealth(mux, h)
+}
+
+// handleStats registers the stats endpoints on mux.
+func handleStats(mux *http.ServeMux, si StatsInformer, tor bool, limit *RateLimiter, trustedProxies TrustedProxies) {
+	statsJSONHandler := http.HandlerFunc(statsJSON(si, tor, trustedProxies))
+	mux.Handle("/stats", limit.handler(statsJSONHandler, trustedProxies.clientIP))
+	mux.Handle("/v1/stats", limit.handler(http.HandlerFunc(statsV1(si, tor, trustedProxies)), trustedProxies.clientIP))
+	mux.HandleFunc("/v1/openapi.json", statsV1OpenAPI)
+	mux.Handle("/identity", limit.handler(http.HandlerFunc(serverIdentityJSON(si)), trustedProxies.clientIP))
+
+	if rh, ok := si.(roundHistorian); ok {
+		roundsHandler := limit.handler(http.HandlerFunc(roundsV1(si, rh)), trustedProxies.clientIP)
+		mux.Handle("/v1/rounds", roundsHandler)
+		mux.Handle("/v1/rounds/", roundsHandler)
+	}
+
+	if ps, ok := si.(poolSubscriber); ok {
+		mux.Handle("/stats/stream", limit.handler(http.HandlerFunc(statsStream(ps)), trustedProxies.clientIP))
+	}
+}
+
+func statsJSON(si StatsInformer, tor bool, trustedProxies TrustedProxies) func(http.ResponseWriter, *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
-		ip, _, _ := net.SplitHostPort(r.RemoteAddr)
-		b, _ := json.Marshal(si.Stats(ip, tor))
+		b, _ := json.Marshal(si.Stats(trustedProxies.clientIP(r), tor))
 		w.Header().Set("Content-Type", "application/json")
 		w.Header().Set("Access-Control-Allow-Origin", "*")
 		w.Header().Set("Access-Control-Allow-Headers", "Origin, X-Requested-With, Content-Type, Accept")
-		w.Write(b)
+		w.Header().Set("Access-Control-Expose-Headers", signedStatsHeaders)
+		writeStats(w, si, b)
 	}
 }
 
-func newStatsServer(addr string, mux *http.ServeMux, m *autocert.Manager) *http.Server {
+func newStatsServer(addr string, mux *http.ServeMux) *http.Server {
 	srv := &http.Server{
 		Addr:         addr,
 		Handler:      mux,
-		TLSConfig:    &tls.Config{},
 		ReadTimeout:  5 * time.Second,
 		WriteTimeout: 10 * time.Second,
 		IdleTimeout:  15 * time.Second,
 	}
 
-	if m != nil {
-		srv.TLSConfig.GetCertificate = m.GetCertificate
-	}
-
 	return srv
 }
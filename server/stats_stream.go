@@ -0,0 +1,291 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// poolStreamBuffer is the number of deltas a stream subscriber may
+	// fall behind before it is dropped.
+	poolStreamBuffer = 64
+
+	// poolStreamKeepalive is how often an idle stream sends a comment so
+	// that proxies do not close it.
+	poolStreamKeepalive = 30 * time.Second
+
+	// poolStreamWriteTimeout is how long a write to a stream may take.
+	poolStreamWriteTimeout = 10 * time.Second
+)
+
+// PoolDelta is a change to a pool sent by the stats stream. Removed is
+// set when the pool is gone, in which case only the ID is meaningful.
+type PoolDelta struct {
+	PoolStats
+	Removed bool `json:"removed,omitempty"`
+}
+
+// PoolFilter selects pools by their stats. Zero values match every pool.
+type PoolFilter struct {
+	Amount  uint64
+	Type    string
+	Version uint64
+	Full    *bool
+}
+
+// matches returns true if the filter selects a pool.
+func (f PoolFilter) matches(ps PoolStats) bool {
+	if f.Amount != 0 && f.Amount != ps.Amount {
+		return false
+	}
+
+	if f.Type != "" && !strings.EqualFold(f.Type, ps.Type) {
+		return false
+	}
+
+	if f.Version != 0 && f.Version != ps.Version {
+		return false
+	}
+
+	if f.Full != nil && *f.Full != ps.Full {
+		return false
+	}
+
+	return true
+}
+
+// parsePoolFilter reads a filter from the amount, type, version and full
+// query parameters.
+func parsePoolFilter(query url.Values) (PoolFilter, error) {
+	f := PoolFilter{Type: query.Get("type")}
+
+	if s := query.Get("full"); s != "" {
+		full, err := strconv.ParseBool(s)
+		if err != nil {
+			return f, fmt.Errorf("invalid full: %s", s)
+		}
+
+		f.Full = &full
+	}
+
+	for _, p := range []struct {
+		name  string
+		value *uint64
+	}{
+		{"amount", &f.Amount},
+		{"version", &f.Version},
+	} {
+		s := query.Get(p.name)
+		if s == "" {
+			continue
+		}
+
+		v, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			return f, fmt.Errorf("invalid %s: %s", p.name, s)
+		}
+
+		*p.value = v
+	}
+
+	return f, nil
+}
+
+// poolStream sends pool deltas to stream subscribers.
+type poolStream struct {
+	mutex       sync.Mutex
+	subscribers map[chan PoolDelta]PoolFilter
+}
+
+func newPoolStream() *poolStream {
+	return &poolStream{
+		subscribers: make(map[chan PoolDelta]PoolFilter),
+	}
+}
+
+// subscribe returns a channel receiving the deltas that match f.
+func (s *poolStream) subscribe(f PoolFilter) chan PoolDelta {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	c := make(chan PoolDelta, poolStreamBuffer)
+	s.subscribers[c] = f
+
+	return c
+}
+
+// unsubscribe stops sending deltas to c.
+func (s *poolStream) unsubscribe(c chan PoolDelta) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, ok := s.subscribers[c]; ok {
+		delete(s.subscribers, c)
+		close(c)
+	}
+}
+
+// publish sends d to the subscribers it matches. Subscribers that have
+// fallen behind are dropped, so publishing never blocks the tracker.
+func (s *poolStream) publish(d PoolDelta) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for c, f := range s.subscribers {
+		if !f.matches(d.PoolStats) {
+			continue
+		}
+
+		select {
+		case c <- d:
+		default:
+			delete(s.subscribers, c)
+			close(c)
+		}
+	}
+}
+
+// poolSubscriber streams pool deltas. The tracker implements it.
+type poolSubscriber interface {
+	subscribePools(f PoolFilter) ([]PoolStats, chan PoolDelta)
+	unsubscribePools(c chan PoolDelta)
+}
+
+// poolStats returns the stats of a pool.
+func poolStats(p *Pool) PoolStats {
+	return PoolStats{
+		ID:      p.num,
+		Members: p.PlayerCount(),
+		Amount:  p.amount,
+		Type:    p.shuffleType.String(),
+		Full:    p.IsFrozen(),
+		Version: p.version,
+
+		CreatedAt:     p.created.Unix(),
+		SinceLastJoin: int64(time.Since(p.LastJoin()) / time.Second),
+		Round:         p.Round(),
+	}
+}
+
+// publishPool sends the current state of a pool to stream subscribers.
+// This method assumes the caller is holding the mutex.
+func (t *Tracker) publishPool(p *Pool) {
+	t.poolStream.publish(PoolDelta{PoolStats: poolStats(p)})
+}
+
+// publishPoolRemoved tells stream subscribers a pool is gone.
+// This method assumes the caller is holding the mutex.
+func (t *Tracker) publishPoolRemoved(p *Pool) {
+	ps := poolStats(p)
+	ps.Members = 0
+
+	t.poolStream.publish(PoolDelta{PoolStats: ps, Removed: true})
+}
+
+// subscribePools returns the pools matching f and a channel receiving
+// their later deltas. Deltas are published while holding the mutex, so
+// none are missed or repeated between the snapshot and the channel.
+func (t *Tracker) subscribePools(f PoolFilter) ([]PoolStats, chan PoolDelta) {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+
+	pools := make([]PoolStats, 0)
+	for _, p := range t.pools {
+		if ps := poolStats(p); f.matches(ps) {
+			pools = append(pools, ps)
+		}
+	}
+
+	return pools, t.poolStream.subscribe(f)
+}
+
+// unsubscribePools stops sending deltas to c.
+func (t *Tracker) unsubscribePools(c chan PoolDelta) {
+	t.poolStream.unsubscribe(c)
+}
+
+// statsStream streams pool deltas as server sent events. The current
+// pools are sent first, then every change to a matching pool.
+func statsStream(ps poolSubscriber) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		f, err := parsePoolFilter(r.URL.Query())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		// a pool filling up would leave the stream of a subscriber
+		// filtering on full without the delta that removes it
+		if f.Full != nil {
+			http.Error(w, "full can not be streamed", http.StatusBadRequest)
+			return
+		}
+
+		rc := http.NewResponseController(w)
+
+		pools, c := ps.subscribePools(f)
+		defer ps.unsubscribePools(c)
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+
+		write := func(event string) error {
+			if err := rc.SetWriteDeadline(time.Now().Add(poolStreamWriteTimeout)); err != nil {
+				return err
+			}
+
+			if _, err := fmt.Fprint(w, event); err != nil {
+				return err
+			}
+
+			return rc.Flush()
+		}
+
+		for _, p := range pools {
+			if err := write(poolEvent(PoolDelta{PoolStats: p})); err != nil {
+				return
+			}
+		}
+
+		if err := write(": ready\n\n"); err != nil {
+			return
+		}
+
+		keepalive := time.NewTicker(poolStreamKeepalive)
+		defer keepalive.Stop()
+
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-keepalive.C:
+				if err := write(": keepalive\n\n"); err != nil {
+					return
+				}
+			case d, ok := <-c:
+				// the subscriber was dropped for falling behind
+				if !ok {
+					return
+				}
+
+				if err := write(poolEvent(d)); err != nil {
+					return
+				}
+			}
+		}
+	}
+}
+
+// poolEvent formats a delta as a server sent event.
+func poolEvent(d PoolDelta) string {
+	b, _ := json.Marshal(d)
+
+	return fmt.Sprintf("event: pool\ndata: %s\n\n", b)
+}
@@ -0,0 +1,130 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/ulule/limiter/v3"
+	"github.com/ulule/limiter/v3/drivers/store/memory"
+)
+
+// readPoolEvent reads the next pool event from a stats stream, skipping
+// comments. Nil is returned when the ready comment is read.
+func readPoolEvent(t *testing.T, r *bufio.Reader) *PoolDelta {
+	for {
+		line, err := r.ReadString('\n')
+		require.NoError(t, err)
+
+		switch {
+		case line == ": ready\n":
+			return nil
+		case strings.HasPrefix(line, "data: "):
+			var d PoolDelta
+			require.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &d))
+			return &d
+		}
+	}
+}
+
+func TestStatsStream(t *testing.T) {
+	tracker := NewTracker(3, 1337, 1338, 0, 0)
+	limit := NewRateLimiter(memory.NewStore(), limiter.Rate{Period: time.Minute, Limit: 10})
+
+	mux := http.NewServeMux()
+	handleStats(mux, tracker, false, limit, nil)
+
+	s := httptest.NewServer(mux)
+	defer s.Close()
+
+	add := func(i int, amount uint64) *PlayerData {
+		p := &PlayerData{
+			conn:            newIPConn(fmt.Sprintf("8.8.8.%d", i)),
+			verificationKey: fmt.Sprintf("vk%d", i),
+			amount:          amount,
+			version:         testVersion,
+		}
+		require.NoError(t, tracker.add(p))
+		return p
+	}
+
+	first := add(1, 100)
+
+	resp, err := http.Get(s.URL + "/stats/stream?amount=100")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	r := bufio.NewReader(resp.Body)
+
+	// the current pools come first
+	d := readPoolEvent(t, r)
+	require.NotNil(t, d)
+	assert.WithinDuration(t, time.Now(), time.Unix(d.CreatedAt, 0), 5*time.Second)
+	d.CreatedAt = 0
+	assert.Equal(t, PoolStats{ID: 1, Members: 1, Amount: 100, Type: "DEFAULT", Version: testVersion}, d.PoolStats)
+	assert.Nil(t, readPoolEvent(t, r))
+
+	// pools with other amounts are filtered out
+	add(2, 200)
+	second := add(3, 100)
+	third := add(4, 100)
+
+	d = readPoolEvent(t, r)
+	assert.Equal(t, 1, d.ID)
+	assert.Equal(t, 2, d.Members)
+
+	d = readPoolEvent(t, r)
+	assert.Equal(t, 3, d.Members)
+	assert.True(t, d.Full)
+
+	for _, p := range []*PlayerData{first, second, third} {
+		tracker.remove(p.conn)
+	}
+
+	assert.Equal(t, 2, readPoolEvent(t, r).Members)
+	assert.Equal(t, 1, readPoolEvent(t, r).Members)
+
+	d = readPoolEvent(t, r)
+	assert.Equal(t, 1, d.ID)
+	assert.True(t, d.Removed)
+}
+
+func TestStatsStreamRejectsBadFilter(t *testing.T) {
+	mux := http.NewServeMux()
+	handleStats(mux, NewTracker(3, 1337, 1338, 0, 0), false, NewRateLimiter(memory.NewStore(), limiter.Rate{Period: time.Minute, Limit: 10}), nil)
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", "/stats/stream?version=new", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", "/stats/stream?full=false", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestPoolStreamDropsSlowSubscribers(t *testing.T) {
+	s := newPoolStream()
+	c := s.subscribe(PoolFilter{})
+
+	for i := 0; i <= poolStreamBuffer; i++ {
+		s.publish(PoolDelta{PoolStats: PoolStats{ID: i}})
+	}
+
+	// the buffered deltas can still be read before the channel closes
+	n := 0
+	for range c {
+		n++
+	}
+	assert.Equal(t, poolStreamBuffer, n)
+
+	// unsubscribing after being dropped is safe
+	s.unsubscribe(c)
+}
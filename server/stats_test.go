@@ -1,14 +1,19 @@
 package server
 
 import (
+	"fmt"
 	"net"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestTrackStats(t *testing.T) {
+	created := time.Now().Add(-time.Hour)
+	lastJoin := time.Now().Add(-time.Minute)
+
 	tracker := &Tracker{
 		connections: map[net.Conn]*PlayerData{
 			&fakeConn{}: {},
@@ -21,6 +26,7 @@ func TestTrackStats(t *testing.T) {
 			&fakeConn{}: {},
 		},
 		denyIPMatch: map[ipPair]time.Time{},
+		health:      NewHealth(),
 		pools: map[int]*Pool{
 			1: {
 				num: 1,
@@ -35,6 +41,8 @@ func TestTrackStats(t *testing.T) {
 				amount:      100,
 				shuffleType: 0,
 				version:     0,
+				created:     created,
+				lastJoin:    lastJoin,
 				frozenSnapshot: map[string]*PlayerData{
 					"1": nil,
 					"2": nil,
@@ -54,6 +62,8 @@ func TestTrackStats(t *testing.T) {
 				amount:         1000,
 				shuffleType:    1,
 				version:        1,
+				created:        created,
+				lastJoin:       lastJoin,
 				frozenSnapshot: map[string]*PlayerData{},
 			},
 		},
@@ -84,18 +94,26 @@ func TestTrackStats(t *testing.T) {
 	assert.Equal(t, 3001, stats.ShuffleWebSocketPort)
 	assert.Contains(t, stats.Pools,
 		PoolStats{
+			ID:      1,
 			Members: 5,
 			Amount:  100,
 			Type:    "DEFAULT",
 			Full:    true,
 			Version: 0,
+
+			CreatedAt:     created.Unix(),
+			SinceLastJoin: 60,
 		},
 		PoolStats{
+			ID:      2,
 			Members: 3,
 			Amount:  1000,
 			Type:    "DUST",
 			Full:    false,
 			Version: 1,
+
+			CreatedAt:     created.Unix(),
+			SinceLastJoin: 60,
 		},
 	)
 
@@ -111,22 +129,88 @@ func TestTrackStats(t *testing.T) {
 	assert.Equal(t, 3003, stats2.ShuffleWebSocketPort)
 	assert.Contains(t, stats2.Pools,
 		PoolStats{
+			ID:      1,
 			Members: 5,
 			Amount:  100,
 			Type:    "DEFAULT",
 			Full:    true,
 			Version: 0,
+
+			CreatedAt:     created.Unix(),
+			SinceLastJoin: 60,
 		},
 		PoolStats{
+			ID:      2,
 			Members: 3,
 			Amount:  1000,
 			Type:    "DUST",
 			Full:    false,
 			Version: 1,
+
+			CreatedAt:     created.Unix(),
+			SinceLastJoin: 60,
 		},
 	)
 }
 
+func TestTierStats(t *testing.T) {
+	tracker := NewTracker(basicPoolSize, 1337, 1338, 0, 0)
+
+	add := func(i int, amount uint64) *PlayerData {
+		p := &PlayerData{
+			conn:            newIPConn(fmt.Sprintf("8.8.8.%d", i)),
+			verificationKey: fmt.Sprintf("vk%d", i),
+			amount:          amount,
+			version:         testVersion,
+		}
+		require.NoError(t, tracker.add(p))
+		return p
+	}
+
+	// a pool that gets to the last phase and empties without a ban
+	// completes
+	var players []*PlayerData
+	for i := 0; i < basicPoolSize; i++ {
+		players = append(players, add(i, 100))
+	}
+	players[0].pool.recordPhase(submitted)
+	for _, p := range players {
+		tracker.remove(p.conn)
+	}
+
+	// a pool that empties before filling does not
+	tracker.remove(add(10, 100).conn)
+
+	add(20, 200)
+	add(21, 200)
+
+	// completions older than an hour only count towards the last day
+	tier := poolTier{amount: 100, version: testVersion}
+	ended := func(ago time.Duration, tier poolTier, outcome string) RoundStats {
+		return RoundStats{EndedAt: time.Now().Add(-ago).Unix(), Outcome: outcome, tier: tier}
+	}
+	tracker.rounds = append([]RoundStats{
+		ended(25*time.Hour, tier, RoundCompleted),
+		ended(2*time.Hour, tier, RoundCompleted),
+		ended(time.Minute, tier, RoundBanned),
+		ended(time.Minute, tier, RoundAbandoned),
+	}, tracker.rounds...)
+
+	assert.Equal(t, []TierStats{
+		{Amount: 100, Type: "DEFAULT", Version: testVersion, Completed1h: 1, Completed24h: 2},
+		{Amount: 200, Type: "DEFAULT", Version: testVersion, Pools: 1, Members: 2},
+	}, tracker.Stats("", false).Tiers)
+
+	// rounds that ended too long ago are forgotten, running ones are kept
+	tracker.rounds = append(tracker.rounds, RoundStats{Outcome: RoundRunning, tier: tier})
+	tracker.pruneRounds()
+
+	require.Len(t, tracker.rounds, 5)
+	for _, r := range tracker.rounds {
+		assert.True(t, r.EndedAt == 0 || time.Since(time.Unix(r.EndedAt, 0)) < tierHistoryLength)
+	}
+}
+
 type fakeConn struct {
 	f interface{}
 }
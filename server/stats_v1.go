@@ -0,0 +1,157 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+)
+
+const (
+	// statsV1DefaultLimit is the number of pools in a page when the
+	// request does not set a limit.
+	statsV1DefaultLimit = 50
+
+	// statsV1MaxLimit is the largest page a request may ask for.
+	statsV1MaxLimit = 500
+)
+
+// StatsV1 is the response of /v1/stats. Pools holds one page of the
+// pools matching the request, ordered by ID. Next is the cursor of the
+// following page and is omitted on the last page.
+type StatsV1 struct {
+	BanScore             uint32         `json:"banScore"`
+	Banned               bool           `json:"banned"`
+	Connections          int            `json:"connections"`
+	PoolSize             int            `json:"poolSize"`
+	ShufflePort          int            `json:"shufflePort"`
+	ShuffleWebSocketPort int            `json:"shuffleWebSocketPort"`
+	Rejections           RejectionStats `json:"rejections"`
+	OnionAddress         string         `json:"onionAddress,omitempty"`
+	Draining             bool           `json:"draining"`
+	Tiers                []TierStats    `json:"tiers"`
+	PublicKey            string         `json:"publicKey,omitempty"`
+	SignedPacketsVersion uint64         `json:"signedPacketsVersion,omitempty"`
+	Pools                []PoolStats    `json:"pools"`
+	Total                int            `json:"total"`
+	Next                 int            `json:"next,omitempty"`
+}
+
+// statsV1Page selects the pools after a cursor.
+type statsV1Page struct {
+	after int
+	limit int
+}
+
+// parseStatsV1Page reads a page from the after and limit query
+// parameters.
+func parseStatsV1Page(query url.Values) (statsV1Page, error) {
+	p := statsV1Page{limit: statsV1DefaultLimit}
+
+	if s := query.Get("after"); s != "" {
+		after, err := strconv.Atoi(s)
+		if err != nil || after < 0 {
+			return p, fmt.Errorf("invalid after: %s", s)
+		}
+
+		p.after = after
+	}
+
+	if s := query.Get("limit"); s != "" {
+		limit, err := strconv.Atoi(s)
+		if err != nil || limit < 1 || limit > statsV1MaxLimit {
+			return p, fmt.Errorf("invalid limit: %s", s)
+		}
+
+		p.limit = limit
+	}
+
+	return p, nil
+}
+
+// newStatsV1 returns the page of pools in ts matching f.
+func newStatsV1(ts *TrackerStats, f PoolFilter, p statsV1Page) *StatsV1 {
+	s := &StatsV1{
+		BanScore:             ts.BanScore,
+		Banned:               ts.Banned,
+		Connections:          ts.Connections,
+		PoolSize:             ts.PoolSize,
+		ShufflePort:          ts.ShufflePort,
+		ShuffleWebSocketPort: ts.ShuffleWebSocketPort,
+		Rejections:           ts.Rejections,
+		OnionAddress:         ts.OnionAddress,
+		Draining:             ts.Draining,
+		Tiers:                ts.Tiers,
+		PublicKey:            ts.PublicKey,
+		SignedPacketsVersion: ts.SignedPacketsVersion,
+		Pools:                make([]PoolStats, 0),
+	}
+
+	pools := make([]PoolStats, 0, len(ts.Pools))
+	for _, ps := range ts.Pools {
+		if f.matches(ps) {
+			pools = append(pools, ps)
+		}
+	}
+
+	sort.Slice(pools, func(i, j int) bool {
+		return pools[i].ID < pools[j].ID
+	})
+
+	s.Total = len(pools)
+
+	for _, ps := range pools {
+		if ps.ID <= p.after {
+			continue
+		}
+
+		if len(s.Pools) == p.limit {
+			s.Next = s.Pools[len(s.Pools)-1].ID
+			break
+		}
+
+		s.Pools = append(s.Pools, ps)
+	}
+
+	return s
+}
+
+// statsV1 serves the stats of the pools matching the query.
+func statsV1(si StatsInformer, tor bool, trustedProxies TrustedProxies) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Set("Access-Control-Expose-Headers", signedStatsHeaders)
+
+		f, err := parsePoolFilter(r.URL.Query())
+		if err != nil {
+			statsV1Error(w, err)
+			return
+		}
+
+		p, err := parseStatsV1Page(r.URL.Query())
+		if err != nil {
+			statsV1Error(w, err)
+			return
+		}
+
+		b, _ := json.Marshal(newStatsV1(si.Stats(trustedProxies.clientIP(r), tor), f, p))
+		writeStats(w, si, b)
+	}
+}
+
+// statsV1Error tells the client its request was invalid.
+func statsV1Error(w http.ResponseWriter, err error) {
+	b, _ := json.Marshal(map[string]string{"error": err.Error()})
+	w.WriteHeader(http.StatusBadRequest)
+	w.Write(b)
+}
+
+// statsV1OpenAPI serves the OpenAPI description of the stats API.
+func statsV1OpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Write([]byte(StatsOpenAPI))
+}
@@ -0,0 +1,137 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/ulule/limiter/v3"
+	"github.com/ulule/limiter/v3/drivers/store/memory"
+)
+
+// getStatsV1 requests /v1/stats and returns its status code and body.
+func getStatsV1(t *testing.T, mux *http.ServeMux, query string) (int, StatsV1) {
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", "/v1/stats?"+query, nil))
+
+	var s StatsV1
+	if w.Code == http.StatusOK {
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &s))
+	}
+
+	return w.Code, s
+}
+
+func TestStatsV1(t *testing.T) {
+	tracker := NewTracker(basicPoolSize, 1337, 1338, 0, 0)
+	limit := NewRateLimiter(memory.NewStore(), limiter.Rate{Period: time.Minute, Limit: 100})
+
+	mux := http.NewServeMux()
+	handleStats(mux, tracker, false, limit, nil)
+
+	// each amount gets its own pool, and the first fills up
+	var players []*PlayerData
+	for i, amount := range []uint64{100, 100, 100, 200, 300, 400, 500} {
+		p := &PlayerData{
+			conn:            newIPConn(fmt.Sprintf("8.8.8.%d", i)),
+			verificationKey: fmt.Sprintf("vk%d", i),
+			amount:          amount,
+			version:         testVersion,
+		}
+		require.NoError(t, tracker.add(p))
+		players = append(players, p)
+	}
+
+	ids := func(s StatsV1) []int {
+		ids := make([]int, 0)
+		for _, p := range s.Pools {
+			ids = append(ids, p.ID)
+		}
+		return ids
+	}
+
+	code, s := getStatsV1(t, mux, "")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, []int{1, 2, 3, 4, 5}, ids(s))
+	assert.Equal(t, 5, s.Total)
+	assert.Equal(t, 0, s.Next)
+	assert.Equal(t, 7, s.Connections)
+
+	_, s = getStatsV1(t, mux, "amount=100")
+	assert.Equal(t, []int{1}, ids(s))
+
+	_, s = getStatsV1(t, mux, "full=false")
+	assert.Equal(t, []int{2, 3, 4, 5}, ids(s))
+
+	_, s = getStatsV1(t, mux, "full=false&limit=2")
+	assert.Equal(t, []int{2, 3}, ids(s))
+	assert.Equal(t, 4, s.Total)
+	assert.Equal(t, 3, s.Next)
+
+	// pages stay stable when the pool at the cursor goes away
+	tracker.remove(players[4].conn)
+
+	_, s = getStatsV1(t, mux, fmt.Sprintf("full=false&limit=2&after=%d", s.Next))
+	assert.Equal(t, []int{4, 5}, ids(s))
+	assert.Equal(t, 3, s.Total)
+	assert.Equal(t, 0, s.Next)
+
+	for _, query := range []string{"limit=0", "limit=501", "after=-1", "full=maybe", "amount=lots"} {
+		code, _ = getStatsV1(t, mux, query)
+		assert.Equal(t, http.StatusBadRequest, code, query)
+	}
+}
+
+func TestStatsOpenAPI(t *testing.T) {
+	mux := http.NewServeMux()
+	handleStats(mux, NewTracker(basicPoolSize, 1337, 1338, 0, 0), false, NewRateLimiter(memory.NewStore(), limiter.Rate{Period: time.Minute, Limit: 1}), nil)
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", "/v1/openapi.json", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var doc struct {
+		Paths      map[string]interface{}
+		Components struct {
+			Schemas map[string]struct {
+				Properties map[string]interface{}
+			}
+		}
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &doc))
+	assert.Contains(t, doc.Paths, "/v1/stats")
+	assert.Contains(t, doc.Paths, "/v1/rounds")
+	assert.Contains(t, doc.Paths, "/v1/rounds/{id}")
+
+	// every field of the response is described
+	b, err := json.Marshal(StatsV1{OnionAddress: "x", Next: 1, PublicKey: "x", SignedPacketsVersion: 1, Pools: []PoolStats{{Round: "x"}}, Tiers: []TierStats{{}}})
+	require.NoError(t, err)
+
+	var stats map[string]interface{}
+	require.NoError(t, json.Unmarshal(b, &stats))
+
+	for field := range stats {
+		assert.Contains(t, doc.Components.Schemas["Stats"].Properties, field)
+	}
+
+	for name, schema := range map[string]string{"pools": "Pool", "tiers": "Tier"} {
+		for field := range stats[name].([]interface{})[0].(map[string]interface{}) {
+			assert.Contains(t, doc.Components.Schemas[schema].Properties, field, schema)
+		}
+	}
+
+	b, err = json.Marshal(RoundStats{EndedAt: 1})
+	require.NoError(t, err)
+
+	var round map[string]interface{}
+	require.NoError(t, json.Unmarshal(b, &round))
+
+	for field := range round {
+		assert.Contains(t, doc.Components.Schemas["Round"].Properties, field)
+	}
+}
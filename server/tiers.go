@@ -0,0 +1,101 @@
+package server
+
+import (
+	"sort"
+	"time"
+
+	"github.com/cashshuffle/cashshuffle/message"
+)
+
+// tierHistoryLength is how long completed rounds are counted.
+const tierHistoryLength = 24 * time.Hour
+
+// TierStats aggregates the pools of an amount, type and version. It only
+// holds counts, so it can not be linked to individual players.
+type TierStats struct {
+	Amount       uint64 `json:"amount"`
+	Type         string `json:"type"`
+	Version      uint64 `json:"version"`
+	Pools        int    `json:"pools"`
+	Members      int    `json:"members"`
+	Completed1h  int    `json:"completed1h"`
+	Completed24h int    `json:"completed24h"`
+}
+
+// poolTier is the amount, type and version players must share to be
+// placed in the same pool.
+type poolTier struct {
+	amount      uint64
+	shuffleType message.ShuffleType
+	version     uint64
+}
+
+// tier returns the tier of a pool.
+func (pool *Pool) tier() poolTier {
+	return poolTier{
+		amount:      pool.amount,
+		shuffleType: pool.shuffleType,
+		version:     pool.version,
+	}
+}
+
+// tierStats returns the stats of every tier with pools or completed
+// rounds.
+// This method assumes the caller is holding the mutex.
+func (t *Tracker) tierStats() []TierStats {
+	now := time.Now()
+	tiers := make(map[poolTier]*TierStats)
+
+	get := func(tier poolTier) *TierStats {
+		if tiers[tier] == nil {
+			tiers[tier] = &TierStats{
+				Amount:  tier.amount,
+				Type:    tier.shuffleType.String(),
+				Version: tier.version,
+			}
+		}
+
+		return tiers[tier]
+	}
+
+	for _, pool := range t.pools {
+		ts := get(pool.tier())
+		ts.Pools++
+		ts.Members += pool.PlayerCount()
+	}
+
+	for _, r := range t.rounds {
+		if r.Outcome != RoundCompleted {
+			continue
+		}
+
+		age := now.Sub(time.Unix(r.EndedAt, 0))
+		if age > tierHistoryLength {
+			continue
+		}
+
+		ts := get(r.tier)
+		ts.Completed24h++
+		if age <= time.Hour {
+			ts.Completed1h++
+		}
+	}
+
+	stats := make([]TierStats, 0, len(tiers))
+	for _, ts := range tiers {
+		stats = append(stats, *ts)
+	}
+
+	sort.Slice(stats, func(i, j int) bool {
+		a, b := stats[i], stats[j]
+		if a.Amount != b.Amount {
+			return a.Amount < b.Amount
+		}
+		if a.Type != b.Type {
+			return a.Type < b.Type
+		}
+		return a.Version < b.Version
+	})
+
+	return stats
+}
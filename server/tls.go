@@ -2,33 +2,143 @@ package server
 
 import (
 	"crypto/tls"
-	"fmt"
+	"crypto/x509"
 	"net"
+	"sync"
+	"time"
 
 	"golang.org/x/crypto/acme/autocert"
 )
 
-// createTLSListener creates a net.Listener with TLS support.
-func createTLSListener(ip string, port int, cert string, key string, m *autocert.Manager) (net.Listener, error) {
+// certificates holds every key pair loaded from disk so that they can be
+// reloaded without restarting the listeners.
+var certificates = &certificateStore{
+	pairs: make(map[[2]string]*tls.Certificate),
+}
+
+// certificateStore is a set of key pairs keyed by their cert and key paths.
+type certificateStore struct {
+	mutex sync.RWMutex
+	pairs map[[2]string]*tls.Certificate
+}
+
+// load loads a key pair unless it has already been loaded.
+func (s *certificateStore) load(cert string, key string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, ok := s.pairs[[2]string{cert, key}]; ok {
+		return nil
+	}
+
+	cer, err := loadKeyPair(cert, key)
+	if err != nil {
+		return err
+	}
+
+	s.pairs[[2]string{cert, key}] = cer
+
+	return nil
+}
+
+// getCertificate returns a tls.Config GetCertificate function that
+// always serves the latest version of a key pair.
+func (s *certificateStore) getCertificate(cert string, key string) func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+		s.mutex.RLock()
+		defer s.mutex.RUnlock()
+
+		return s.pairs[[2]string{cert, key}], nil
+	}
+}
+
+// reload reads every key pair from disk again. If any pair fails to
+// load, the previous versions keep being served.
+func (s *certificateStore) reload() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	pairs := make(map[[2]string]*tls.Certificate)
+	for paths := range s.pairs {
+		cer, err := loadKeyPair(paths[0], paths[1])
+		if err != nil {
+			return err
+		}
+
+		pairs[paths] = cer
+	}
+
+	s.pairs = pairs
+
+	return nil
+}
+
+// expiry returns when the first key pair expires. False is returned if
+// no key pairs are loaded.
+func (s *certificateStore) expiry() (time.Time, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	var first time.Time
+	for _, cer := range s.pairs {
+		if first.IsZero() || cer.Leaf.NotAfter.Before(first) {
+			first = cer.Leaf.NotAfter
+		}
+	}
+
+	return first, !first.IsZero()
+}
+
+// loadKeyPair loads a key pair and parses its certificate.
+func loadKeyPair(cert string, key string) (*tls.Certificate, error) {
+	cer, err := tls.LoadX509KeyPair(cert, key)
+	if err != nil {
+		return nil, err
+	}
+
+	if cer.Leaf == nil {
+		cer.Leaf, err = x509.ParseCertificate(cer.Certificate[0])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &cer, nil
+}
+
+// ReloadCertificates reloads the TLS certificates of all listeners from
+// disk. New connections use the new certificates, existing connections
+// are not affected.
+func ReloadCertificates() error {
+	return certificates.reload()
+}
+
+// createTLSConfig creates a TLS config serving either the key pair at
+// cert and key or certificates from the autocert manager.
+func createTLSConfig(cert string, key string, m *autocert.Manager) (*tls.Config, error) {
 	c := &tls.Config{}
 
 	if cert != "" && key != "" {
-		cer, err := tls.LoadX509KeyPair(cert, key)
-		if err != nil {
+		if err := certificates.load(cert, key); err != nil {
 			return nil, err
 		}
 
-		c.Certificates = []tls.Certificate{cer}
+		c.GetCertificate = certificates.getCertificate(cert, key)
 	} else {
 		c.GetCertificate = m.GetCertificate
 	}
 
-	listener, err := tls.Listen("tcp", fmt.Sprintf("%s:%d", ip, port), c)
+	return c, nil
+}
+
+// createTLSListener wraps a net.Listener with TLS support.
+func createTLSListener(listener net.Listener, cert string, key string, m *autocert.Manager) (net.Listener, error) {
+	c, err := createTLSConfig(cert, key, m)
 	if err != nil {
 		return nil, err
 	}
 
-	return listener, nil
+	return tls.NewListener(listener, c), nil
 }
 
 // tlsEnabled returns a bool indicating if TLS should be supported.
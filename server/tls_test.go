@@ -0,0 +1,77 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReloadCertificates(t *testing.T) {
+	dir := t.TempDir()
+	cert := filepath.Join(dir, "server.crt")
+	key := filepath.Join(dir, "server.key")
+
+	writeTestCertificate(t, cert, key, "first")
+
+	c, err := createTLSConfig(cert, key, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "first", servedCommonName(t, c.GetCertificate))
+
+	writeTestCertificate(t, cert, key, "second")
+	assert.Equal(t, "first", servedCommonName(t, c.GetCertificate))
+
+	require.NoError(t, ReloadCertificates())
+	assert.Equal(t, "second", servedCommonName(t, c.GetCertificate))
+
+	// a broken key pair keeps the previous certificate
+	require.NoError(t, ioutil.WriteFile(key, []byte("broken"), 0600))
+	assert.Error(t, ReloadCertificates())
+	assert.Equal(t, "second", servedCommonName(t, c.GetCertificate))
+
+	certificates.mutex.Lock()
+	delete(certificates.pairs, [2]string{cert, key})
+	certificates.mutex.Unlock()
+}
+
+func writeTestCertificate(t *testing.T, cert string, key string, commonName string) {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &priv.PublicKey, priv)
+	require.NoError(t, err)
+
+	keyDER, err := x509.MarshalECPrivateKey(priv)
+	require.NoError(t, err)
+
+	require.NoError(t, ioutil.WriteFile(cert, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	require.NoError(t, ioutil.WriteFile(key, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600))
+}
+
+func servedCommonName(t *testing.T, getCertificate func(*tls.ClientHelloInfo) (*tls.Certificate, error)) string {
+	served, err := getCertificate(&tls.ClientHelloInfo{})
+	require.NoError(t, err)
+
+	parsed, err := x509.ParseCertificate(served.Certificate[0])
+	require.NoError(t, err)
+
+	return parsed.Subject.CommonName
+}
@@ -0,0 +1,80 @@
+package server
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+)
+
+const (
+	// torBanKeyPrefix prefixes ban keys for Tor players identified
+	// by their verification key.
+	torBanKeyPrefix = "tor-vk:"
+
+	// torCircuitBanKeyPrefix prefixes ban keys for Tor players identified
+	// by their circuit.
+	torCircuitBanKeyPrefix = "tor-circuit:"
+)
+
+// torCircuitNetwork is the network Tor encodes circuit IDs into when
+// HiddenServiceExportCircuitID is set to haproxy. The global circuit
+// ID is the last 32 bits of the source address in the PROXY header.
+var torCircuitNetwork = &net.IPNet{
+	IP:   net.ParseIP("fc00:dead:beef:4dad::"),
+	Mask: net.CIDRMask(64, maxIPv6PrefixLength),
+}
+
+// torCircuitID returns the Tor circuit ID encoded in an IP, or false
+// if the IP does not carry one.
+func torCircuitID(ip string) (uint32, bool) {
+	parsed := net.ParseIP(ip)
+	if parsed == nil || parsed.To4() != nil || !torCircuitNetwork.Contains(parsed) {
+		return 0, false
+	}
+
+	return binary.BigEndian.Uint32(parsed[12:]), true
+}
+
+// banKey returns the identity that bans and deny matching apply to.
+// Clearnet players are identified by their IP prefix. Tor players all
+// share the address of the Tor daemon, so they are identified by their
+// circuit when Tor exports it and by their verification key otherwise.
+// Clients use a new verification key every round, so the fallback only
+// keeps a player apart within a round and does not make bans stick.
+// This method assumes the caller is holding the mutex.
+func (t *Tracker) banKey(p *PlayerData) string {
+	ip := getIP(p.conn)
+	if !p.tor {
+		return t.ipKey(ip)
+	}
+
+	if key, ok := torCircuitBanKey(ip); ok {
+		return key
+	}
+
+	return torBanKeyPrefix + p.verificationKey
+}
+
+// connBanKey returns the identity that bans apply to for a connection
+// that has not registered yet. False is returned for Tor connections
+// that can only be identified once they register.
+// This method assumes the caller is holding the mutex.
+func (t *Tracker) connBanKey(conn net.Conn) (string, bool) {
+	info := t.openConnections[conn]
+	if info == nil || !info.tor {
+		return t.ipKey(getIP(conn)), true
+	}
+
+	return torCircuitBanKey(info.ip)
+}
+
+// torCircuitBanKey returns the ban key for the Tor circuit encoded in
+// an IP, or false if the IP does not carry one.
+func torCircuitBanKey(ip string) (string, bool) {
+	circuit, ok := torCircuitID(ip)
+	if !ok {
+		return "", false
+	}
+
+	return fmt.Sprintf("%s%d", torCircuitBanKeyPrefix, circuit), true
+}
@@ -0,0 +1,113 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTorCircuitID(t *testing.T) {
+	circuit, ok := torCircuitID("fc00:dead:beef:4dad::1:2")
+	assert.True(t, ok)
+	assert.Equal(t, uint32(0x10002), circuit)
+
+	_, ok = torCircuitID("127.0.0.1")
+	assert.False(t, ok)
+
+	_, ok = torCircuitID("fc00:dead:beef:4dae::1:2")
+	assert.False(t, ok)
+
+	_, ok = torCircuitID("")
+	assert.False(t, ok)
+}
+
+func TestTorBansDoNotAffectOtherTorPlayers(t *testing.T) {
+	tracker := NewTracker(5, 0, 0, 0, 0)
+
+	newTorPlayer := func(vk string) *PlayerData {
+		conn := newIPConn("127.0.0.1")
+		require.NoError(t, tracker.open(conn, true))
+
+		return &PlayerData{
+			conn:            conn,
+			verificationKey: vk,
+			blamedBy:        make(map[string]interface{}),
+		}
+	}
+
+	banned := newTorPlayer("banned")
+	require.NoError(t, tracker.add(banned))
+	for i := 0; i < maxBanScore; i++ {
+		tracker.increaseBanScore(banned, false)
+	}
+
+	assert.Contains(t, tracker.banData, torBanKeyPrefix+"banned")
+	assert.NotContains(t, tracker.banData, "127.0.0.1")
+
+	// other tor users can still connect and register
+	other := newTorPlayer("other")
+	assert.False(t, tracker.bannedByServer(other.conn))
+	assert.NoError(t, tracker.add(other))
+
+	// the banned verification key can not register again
+	tracker.remove(banned.conn)
+	assert.Equal(t, errBanned, tracker.add(newTorPlayer("banned")))
+
+	// clearnet users on the same address are not affected
+	clearnet := newIPConn("127.0.0.1")
+	require.NoError(t, tracker.open(clearnet, false))
+	assert.False(t, tracker.bannedByServer(clearnet))
+}
+
+func TestTorBansByCircuit(t *testing.T) {
+	tracker := NewTracker(5, 0, 0, 0, 0)
+
+	conn := newIPConn("fc00:dead:beef:4dad::1:2")
+	require.NoError(t, tracker.open(conn, true))
+	player := &PlayerData{
+		conn:            conn,
+		verificationKey: "vk",
+		blamedBy:        make(map[string]interface{}),
+	}
+	require.NoError(t, tracker.add(player))
+
+	for i := 0; i < maxBanScore; i++ {
+		tracker.increaseBanScore(player, false)
+	}
+	assert.Contains(t, tracker.banData, torCircuitBanKeyPrefix+"65538")
+	assert.True(t, tracker.Stats("fc00:dead:beef:4dad::1:2", true).Banned)
+	assert.False(t, tracker.Stats("fc00:dead:beef:4dad::1:2", false).Banned)
+
+	// the circuit is banned as soon as it connects
+	again := newIPConn("fc00:dead:beef:4dad::1:2")
+	require.NoError(t, tracker.open(again, true))
+	assert.True(t, tracker.bannedByServer(again))
+
+	otherCircuit := newIPConn("fc00:dead:beef:4dad::1:3")
+	require.NoError(t, tracker.open(otherCircuit, true))
+	assert.False(t, tracker.bannedByServer(otherCircuit))
+}
+
+func TestTorDenyIPMatch(t *testing.T) {
+	tracker := NewTracker(5, 0, 0, 0, 0)
+
+	accused := &PlayerData{conn: newIPConn("127.0.0.1"), verificationKey: "accused", tor: true}
+	other := &PlayerData{conn: newIPConn("127.0.0.1"), verificationKey: "other", tor: true}
+	pool := &Pool{
+		frozenSnapshot: map[string]*PlayerData{
+			"accused": accused,
+			"other":   other,
+		},
+	}
+	tracker.addDenyIPMatch(accused, pool, false)
+
+	assert.Len(t, tracker.denyIPMatch, 1)
+	assert.Contains(t, tracker.denyIPMatch, newIPPair(torBanKeyPrefix+"accused", torBanKeyPrefix+"other"))
+
+	newPool := &Pool{
+		players: map[uint32]*PlayerData{1: other},
+	}
+	assert.True(t, tracker.deniedByIPMatch(accused, newPool, nil))
+	assert.False(t, tracker.deniedByIPMatch(&PlayerData{conn: newIPConn("127.0.0.1"), verificationKey: "new", tor: true}, newPool, nil))
+}
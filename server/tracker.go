@@ -1,13 +1,12 @@
 package server
 
 import (
+	"fmt"
 	"net"
 	"sync"
 	"time"
 
 	"github.com/nats-io/nuid"
-
-	log "github.com/sirupsen/logrus"
 )
 
 const (
@@ -29,14 +28,11 @@ const (
 	// firstPoolNum is the starting number for pools
 	firstPoolNum = 1
 
-	// log buckets
-	logPhaseAnnounce = "[Announce] "
-	logBan           = "[Ban] "
-	logBlame         = "[Blame] "
-	logBroadcast     = "[Broadcast] "
-	logCommunication = "[Communication] "
-	logDirectMessage = "[DirectMessage] "
-	logListener      = "[Listener] "
+	// defaultIPv4PrefixLength is the default prefix IPv4 bans apply to.
+	defaultIPv4PrefixLength = 32
+
+	// defaultIPv6PrefixLength is the default prefix IPv6 bans apply to.
+	defaultIPv6PrefixLength = 64
 )
 
 // Tracker is used to track connections to the server.
@@ -52,6 +48,25 @@ type Tracker struct {
 	shuffleWebSocketPort    int
 	torShufflePort          int
 	torShuffleWebSocketPort int
+	ipv4PrefixLength        int
+	ipv6PrefixLength        int
+	limits                  ConnectionLimits
+	openConnections         map[net.Conn]*connInfo
+	connectionsByIP         map[string]int
+	connectionsByIPKey      map[string]int
+	rejections              RejectionStats
+	poolSeparation          PoolSeparation
+	onionAddress            string
+	banStore                BanStore
+	poolStream              *poolStream
+	poolsCreated            int
+	identity                *Identity
+	rounds                  []RoundStats
+	serveRoundHistory       bool
+	resumeGracePeriod       time.Duration
+	versions                []uint64
+	held                    map[string]*PlayerData
+	health                  *Health
 }
 
 // banData is the data required to track IP bans.
@@ -59,7 +74,7 @@ type banData struct {
 	score uint32
 }
 
-// ipPair is a canonically sorted pair of IPs
+// ipPair is a canonically sorted pair of ban keys
 type ipPair struct {
 	left  string
 	right string
@@ -85,6 +100,14 @@ func NewTracker(poolSize int, shufflePort int, shuffleWebSocketPort int, torShuf
 		shuffleWebSocketPort:    shuffleWebSocketPort,
 		torShufflePort:          torShufflePort,
 		torShuffleWebSocketPort: torShuffleWebSocketPort,
+		ipv4PrefixLength:        defaultIPv4PrefixLength,
+		ipv6PrefixLength:        defaultIPv6PrefixLength,
+		openConnections:         make(map[net.Conn]*connInfo),
+		connectionsByIP:         make(map[string]int),
+		connectionsByIPKey:      make(map[string]int),
+		poolStream:              newPoolStream(),
+		held:                    make(map[string]*PlayerData),
+		health:                  NewHealth(),
 	}
 
 	cleanupDeniedTicker := time.NewTicker(time.Minute)
@@ -92,24 +115,106 @@ func NewTracker(poolSize int, shufflePort int, shuffleWebSocketPort int, torShuf
 	go func() {
 		for range cleanupDeniedTicker.C {
 			t.CleanupDeniedByIPMatch()
+			t.pruneRounds()
 		}
 	}()
 
 	return t
 }
 
-// add adds a connection to the tracker.
-func (t *Tracker) add(p *PlayerData) {
+// SetPrefixLengths sets the prefix lengths that IPv4 and IPv6
+// addresses are grouped by for bans and deny matching.
+func (t *Tracker) SetPrefixLengths(ipv4PrefixLength int, ipv6PrefixLength int) error {
+	if err := validatePrefixLengths(ipv4PrefixLength, ipv6PrefixLength); err != nil {
+		return err
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.ipv4PrefixLength = ipv4PrefixLength
+	t.ipv6PrefixLength = ipv6PrefixLength
+
+	return nil
+}
+
+// SetPoolSize sets the size of new pools. Pools that already exist
+// keep the size they were created with.
+func (t *Tracker) SetPoolSize(poolSize int) error {
+	if poolSize < 1 {
+		return fmt.Errorf("invalid pool size: %d", poolSize)
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.poolSize = poolSize
+
+	return nil
+}
+
+// SetHealth sets the health state that drain mode is read from.
+func (t *Tracker) SetHealth(h *Health) {
 	t.mutex.Lock()
 	defer t.mutex.Unlock()
 
+	t.health = h
+}
+
+// SetOnionAddress sets the .onion address reported in stats.
+func (t *Tracker) SetOnionAddress(address string) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.onionAddress = address
+}
+
+// ipKey returns the ban and deny matching key for an IP.
+// This method assumes the caller is holding the mutex.
+func (t *Tracker) ipKey(ip string) string {
+	return ipKey(ip, t.ipv4PrefixLength, t.ipv6PrefixLength)
+}
+
+// add adds a connection to the tracker. An error is returned if the
+// player can not be placed in a pool.
+func (t *Tracker) add(p *PlayerData) error {
+	t.mutex.RLock()
+	if info := t.openConnections[p.conn]; info != nil {
+		p.tor = info.tor
+		p.resumable = p.resumable || info.resumable
+	}
+	t.mutex.RUnlock()
+
+	bans := t.storedBans(p)
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.health.isDraining() {
+		return errDraining
+	}
+
+	// Tor players can only be identified once they register.
+	score := t.banScore(t.banKey(p))
+	if bans != nil {
+		score = bans.score
+	}
+
+	if score >= maxBanScore {
+		return errBanned
+	}
+
+	if err := t.assignPool(p, bans); err != nil {
+		return err
+	}
+
 	t.verificationKeys[p.verificationKey] = p.conn
 
 	p.sessionID = t.generateSessionID()
 
 	t.connections[p.conn] = p
 
-	t.assignPool(p)
+	return nil
 }
 
 // remove removes the connection.
@@ -117,16 +222,45 @@ func (t *Tracker) remove(conn net.Conn) {
 	t.mutex.Lock()
 	defer t.mutex.Unlock()
 
+	if player := t.connections[conn]; player != nil {
+		t.removePlayer(player)
+	}
+}
+
+// drop removes a connection the client lost. The slot of a player that
+// can resume is held for the resume grace period instead.
+func (t *Tracker) drop(conn net.Conn) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
 	player := t.connections[conn]
-	if player != nil {
-		if player.verificationKey != "" {
-			delete(t.verificationKeys, player.verificationKey)
-		}
+	if player != nil && !t.hold(player) {
+		t.removePlayer(player)
+	}
+}
 
-		t.unassignPool(player)
+// kick closes a connection the server gave up on. Its player lost the
+// connection because of what it sent, so its slot is not held.
+func (t *Tracker) kick(conn net.Conn) {
+	t.mutex.Lock()
+	if player := t.connections[conn]; player != nil {
+		player.kicked = true
+	}
+	t.mutex.Unlock()
+
+	conn.Close()
+}
 
-		delete(t.connections, conn)
+// removePlayer removes a player and its connection.
+// This method assumes the caller is holding the mutex.
+func (t *Tracker) removePlayer(player *PlayerData) {
+	if player.verificationKey != "" {
+		delete(t.verificationKeys, player.verificationKey)
 	}
+
+	t.unassignPool(player)
+
+	delete(t.connections, player.conn)
 }
 
 // count returns the number of connections to the server.
@@ -139,46 +273,84 @@ func (t *Tracker) count() int {
 
 // bannedByServer returns true if the player has been banned from the server.
 func (t *Tracker) bannedByServer(conn net.Conn) bool {
-	t.mutex.RLock()
-	defer t.mutex.RUnlock()
+	score, ok := t.lookupBanScore(func() (string, bool) {
+		return t.connBanKey(conn)
+	})
 
-	ip := getIP(conn)
+	return ok && score >= maxBanScore
+}
 
-	banData := t.banData[ip]
-	if banData != nil && banData.score >= maxBanScore {
-		return true
+// addDenyIPMatch prevents a player from joining a pool with the other
+// pool members for a timeout period. A ban store is written after the
+// mutex is released, or in the background if the caller holds it.
+func (t *Tracker) addDenyIPMatch(player1 *PlayerData, pool *Pool, haveLock bool) {
+	if haveLock {
+		if write := t.denyIPMatchLocked(player1, pool); write != nil {
+			go write()
+		}
+		return
 	}
 
-	return false
-}
+	t.mutex.Lock()
+	write := t.denyIPMatchLocked(player1, pool)
+	t.mutex.Unlock()
 
-// addDenyIPMatch prevents an IP from joining a pool with the other
-// pool member IPs for a timeout period.
-func (t *Tracker) addDenyIPMatch(player1 net.Conn, pool *Pool, haveLock bool) {
-	if !haveLock {
-		t.mutex.Lock()
-		defer t.mutex.Unlock()
+	if write != nil {
+		write()
 	}
+}
 
-	ip := getIP(player1)
+// denyIPMatchLocked adds the deny matches of a player to the tracker, or
+// returns a function that writes them to the ban store.
+// This method assumes the caller is holding the mutex.
+func (t *Tracker) denyIPMatchLocked(player1 *PlayerData, pool *Pool) func() {
+	ip := t.banKey(player1)
 
+	var others []string
 	for _, otherPlayer := range pool.frozenSnapshot {
-		otherIP := getIP(otherPlayer.conn)
+		otherIP := t.banKey(otherPlayer)
 		if ip == otherIP {
 			continue
 		}
 
+		if t.banStore != nil {
+			others = append(others, otherIP)
+			continue
+		}
+
 		// if a ban somehow already exists, extend it
 		t.denyIPMatch[newIPPair(ip, otherIP)] = time.Now()
 	}
+
+	if t.banStore == nil {
+		return nil
+	}
+
+	store := t.banStore
+	return func() {
+		for _, otherIP := range others {
+			if err := store.DenyMatch(ip, otherIP); err != nil {
+				logBan.Errorf("Unable to deny match for %s, %s: %s\n", logBanKey(ip), logBanKey(otherIP), logError(err))
+			}
+		}
+	}
 }
 
-// deniedByIPMatch returns true if an IP should be denied access to a pool.
+// deniedByIPMatch returns true if a player should be denied access to a
+// pool. With a ban store, the deny matches read by storedBans are used.
 // Caller should hold the mutex.
-func (t *Tracker) deniedByIPMatch(player net.Conn, pool *Pool) bool {
-	ip := getIP(player)
+func (t *Tracker) deniedByIPMatch(player *PlayerData, pool *Pool, bans *storedBans) bool {
+	ip := t.banKey(player)
+
 	for _, otherPlayer := range pool.players {
-		otherIP := getIP(otherPlayer.conn)
+		otherIP := t.banKey(otherPlayer)
+
+		if bans != nil {
+			if bans.denied[otherIP] {
+				return true
+			}
+			continue
+		}
 
 		if _, ok := t.denyIPMatch[newIPPair(ip, otherIP)]; ok {
 			return true
@@ -196,19 +368,45 @@ func (t *Tracker) CleanupDeniedByIPMatch() {
 	for pair, deniedTime := range t.denyIPMatch {
 		if deniedTime.Add(denyIPTime).Before(time.Now()) {
 			delete(t.denyIPMatch, pair)
-			log.Debugf(logBan+"Remove player pair %s, %s\n", pair.left, pair.right)
+			logBan.Debugf("Remove player pair %s, %s\n", logBanKey(pair.left), logBanKey(pair.right))
 		}
 	}
 }
 
-// increaseBanScore increases the ban score for an IP on the server.
-func (t *Tracker) increaseBanScore(conn net.Conn, haveLock bool) {
-	if !haveLock {
-		t.mutex.Lock()
-		defer t.mutex.Unlock()
+// increaseBanScore increases the ban score for a player on the server.
+// A ban store is written after the mutex is released, or in the
+// background if the caller holds it.
+func (t *Tracker) increaseBanScore(p *PlayerData, haveLock bool) {
+	if haveLock {
+		if write := t.increaseBanScoreLocked(p); write != nil {
+			go write()
+		}
+		return
+	}
+
+	t.mutex.Lock()
+	write := t.increaseBanScoreLocked(p)
+	t.mutex.Unlock()
+
+	if write != nil {
+		write()
 	}
+}
 
-	ip := getIP(conn)
+// increaseBanScoreLocked increases the ban score of a player kept in the
+// tracker, or returns a function that increases it in the ban store.
+// This method assumes the caller is holding the mutex.
+func (t *Tracker) increaseBanScoreLocked(p *PlayerData) func() {
+	ip := t.banKey(p)
+
+	if t.banStore != nil {
+		store := t.banStore
+		return func() {
+			if err := store.IncreaseBanScore(ip); err != nil {
+				logBan.Errorf("Unable to increase ban score for %s: %s\n", logBanKey(ip), logError(err))
+			}
+		}
+	}
 
 	if _, ok := t.banData[ip]; ok {
 		t.banData[ip].score += banScoreTick
@@ -219,6 +417,8 @@ func (t *Tracker) increaseBanScore(conn net.Conn, haveLock bool) {
 	}
 
 	go t.cleanupBan(ip)
+
+	return nil
 }
 
 // cleanupBan is the decrementer on the ban score and
@@ -235,7 +435,7 @@ func (t *Tracker) cleanupBan(ip string) {
 
 	if t.banData[ip].score == 0 {
 		delete(t.banData, ip)
-		log.Debugf(logBan+"Remove server ban for %s\n", ip)
+		logBan.Debugf("Remove server ban for %s\n", logBanKey(ip))
 	}
 }
 
@@ -269,19 +469,52 @@ func (t *Tracker) generateSessionID() []byte {
 
 // assignPool assigns a user to a pool.
 // This method assumes the caller is holding the mutex.
-func (t *Tracker) assignPool(p *PlayerData) {
-	pool := t.assignExistingPool(p)
-	if pool == nil {
-		t.assignNewPool(p)
+func (t *Tracker) assignPool(p *PlayerData, bans *storedBans) error {
+	// Once an IP is in the maximum number of pools, it may only
+	// join pools it is already in.
+	var allowed map[*Pool]bool
+	if !p.tor && t.limits.MaxPoolsPerIP > 0 {
+		ipPools := t.poolsForIPKey(t.ipKey(getIP(p.conn)))
+		if len(ipPools) >= t.limits.MaxPoolsPerIP {
+			allowed = ipPools
+		}
 	}
+
+	pool := t.assignExistingPool(p, allowed, bans)
+	if pool != nil {
+		if pool.IsFrozen() {
+			t.startRound(pool)
+		}
+
+		t.publishPool(pool)
+		return nil
+	}
+
+	if allowed != nil {
+		t.rejections.MaxPoolsPerIP++
+		return errMaxPoolsPerIP
+	}
+
+	t.assignNewPool(p)
+
+	return nil
 }
 
 // assignExistingPool finds an existing pool and places the player or returns
-// nil if there is not an available slot
+// nil if there is not an available slot. If allowed is not nil, only those
+// pools are considered.
 // This method assumes the caller is holding the mutex.
-func (t *Tracker) assignExistingPool(p *PlayerData) *Pool {
+func (t *Tracker) assignExistingPool(p *PlayerData, allowed map[*Pool]bool, bans *storedBans) *Pool {
 	for _, pool := range t.pools {
-		if t.deniedByIPMatch(p.conn, pool) {
+		if allowed != nil && !allowed[pool] {
+			continue
+		}
+
+		if t.deniedByIPMatch(p, pool, bans) {
+			continue
+		}
+
+		if t.separatedFromPool(p, pool) {
 			continue
 		}
 		ok := pool.AddPlayer(p)
@@ -292,18 +525,21 @@ func (t *Tracker) assignExistingPool(p *PlayerData) *Pool {
 	return nil
 }
 
-// assignNewPool assigns player to the lowest empty pool number >=1
+// assignNewPool assigns player to a new pool. Pool numbers are never
+// reused, so stats can use them to identify pools.
 // This method assumes the caller is holding the mutex.
 func (t *Tracker) assignNewPool(player *PlayerData) {
-	num := firstPoolNum
-	for {
-		if _, ok := t.pools[num]; !ok {
-			break
-		}
-		num++
-	}
+	num := firstPoolNum + t.poolsCreated
+	t.poolsCreated++
+
 	pool := newPool(num, player, t.poolSize)
 	t.pools[num] = pool
+
+	if pool.IsFrozen() {
+		t.startRound(pool)
+	}
+
+	t.publishPool(pool)
 }
 
 // unassignPool removes a user from a pool.
@@ -314,13 +550,18 @@ func (t *Tracker) unassignPool(p *PlayerData) {
 	// are unblameable by other players,
 	// and probably caused the failure of a shuffle.
 	if p.isPassive {
-		t.increaseBanScore(p.conn, true)
-		log.Debugf(logBan+"Disconnecting passive player: %s\n", p)
+		t.increaseBanScore(p, true)
+		logBan.Debugf("Disconnecting passive player: %s\n", p)
 	}
 
 	pool := p.pool
 	pool.RemovePlayer(p)
 	if pool.PlayerCount() == 0 {
 		delete(t.pools, pool.num)
+		t.endRound(pool)
+		t.publishPoolRemoved(pool)
+		return
 	}
+
+	t.publishPool(pool)
 }
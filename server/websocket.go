@@ -0,0 +1,240 @@
+package server
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// WebsocketSubprotocol is the websocket subprotocol of the shuffle
+	// protocol. Clients may offer it, but do not have to.
+	WebsocketSubprotocol = "cashshuffle"
+
+	// websocketHandshakeTimeout is how long the websocket handshake may take.
+	websocketHandshakeTimeout = 10 * time.Second
+)
+
+// WebsocketOptions configures websocket listeners.
+type WebsocketOptions struct {
+	// PingInterval is how often clients are pinged. Pings are not sent
+	// if it is zero.
+	PingInterval time.Duration
+
+	// PongTimeout is how long a client has to answer a ping.
+	PongTimeout time.Duration
+
+	// Compression enables permessage-deflate for clients that offer it.
+	Compression bool
+
+	// AllowedOrigins are the origins browsers may connect from, such as
+	// https://example.com. Every origin is allowed if it is empty or
+	// contains *. Clients that do not send an Origin header are always
+	// allowed, since only browsers send it.
+	AllowedOrigins []string
+}
+
+// newUpgrader creates the websocket upgrader for opts.
+func newUpgrader(opts WebsocketOptions) *websocket.Upgrader {
+	return &websocket.Upgrader{
+		HandshakeTimeout:  websocketHandshakeTimeout,
+		Subprotocols:      []string{WebsocketSubprotocol},
+		EnableCompression: opts.Compression,
+		CheckOrigin:       checkOrigin(opts.AllowedOrigins),
+	}
+}
+
+// checkOrigin returns a function that allows requests from origins.
+func checkOrigin(origins []string) func(*http.Request) bool {
+	return func(r *http.Request) bool {
+		origin := r.Header.Get("Origin")
+		if origin == "" || len(origins) == 0 {
+			return true
+		}
+
+		u, err := url.Parse(origin)
+		if err != nil {
+			return false
+		}
+
+		for _, allowed := range origins {
+			if allowed == "*" || strings.EqualFold(allowed, u.Scheme+"://"+u.Host) {
+				return true
+			}
+		}
+
+		return false
+	}
+}
+
+// wsConn is a net.Conn reading and writing binary websocket messages.
+// Each write is sent as one message and messages are read as a stream,
+// like the framing of the TCP listener.
+type wsConn struct {
+	ws       *websocket.Conn
+	opts     WebsocketOptions
+	reader   io.Reader
+	received bool
+	done     chan struct{}
+	once     sync.Once
+
+	writeMutex sync.Mutex
+
+	mutex    sync.Mutex
+	deadline time.Time
+}
+
+// newWSConn wraps ws and starts pinging the client.
+func newWSConn(ws *websocket.Conn, opts WebsocketOptions) *wsConn {
+	c := &wsConn{
+		ws:   ws,
+		opts: opts,
+		done: make(chan struct{}),
+	}
+
+	if opts.PingInterval > 0 {
+		ws.SetPongHandler(c.handlePong)
+		go c.ping()
+	}
+
+	return c
+}
+
+// Read reads from the current message, moving on to the next message when
+// it has been read.
+func (c *wsConn) Read(b []byte) (int, error) {
+	for {
+		if c.reader == nil {
+			_, r, err := c.ws.NextReader()
+			if _, ok := err.(*websocket.CloseError); ok {
+				// The client closed the connection, like a TCP client
+				// hanging up.
+				return 0, io.EOF
+			}
+			if err != nil {
+				return 0, err
+			}
+
+			c.reader = r
+			c.received = true
+		}
+
+		n, err := c.reader.Read(b)
+		if err == io.EOF {
+			c.reader = nil
+			if n == 0 {
+				continue
+			}
+
+			err = nil
+		}
+
+		return n, err
+	}
+}
+
+// Write writes b as a binary message.
+func (c *wsConn) Write(b []byte) (int, error) {
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
+
+	if err := c.ws.WriteMessage(websocket.BinaryMessage, b); err != nil {
+		return 0, err
+	}
+
+	return len(b), nil
+}
+
+// Close stops the pings and closes the connection.
+func (c *wsConn) Close() error {
+	c.once.Do(func() {
+		close(c.done)
+	})
+
+	return c.ws.Close()
+}
+
+// LocalAddr returns the local address.
+func (c *wsConn) LocalAddr() net.Addr {
+	return c.ws.LocalAddr()
+}
+
+// RemoteAddr returns the client address.
+func (c *wsConn) RemoteAddr() net.Addr {
+	return c.ws.RemoteAddr()
+}
+
+// SetDeadline sets the read and write deadlines.
+func (c *wsConn) SetDeadline(t time.Time) error {
+	if err := c.SetReadDeadline(t); err != nil {
+		return err
+	}
+
+	return c.SetWriteDeadline(t)
+}
+
+// SetReadDeadline sets the read deadline. Pongs may extend it.
+func (c *wsConn) SetReadDeadline(t time.Time) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.deadline = t
+
+	return c.ws.SetReadDeadline(t)
+}
+
+// SetWriteDeadline sets the write deadline.
+func (c *wsConn) SetWriteDeadline(t time.Time) error {
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
+
+	return c.ws.SetWriteDeadline(t)
+}
+
+// handlePong keeps the connection open while the client answers pings,
+// by extending both the read and the write deadline. Only clients that
+// have sent a message are kept open, so clients still have to register
+// before the connect deadline.
+func (c *wsConn) handlePong(string) error {
+	if !c.received {
+		return nil
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	t := time.Now().Add(c.opts.PingInterval + c.opts.PongTimeout)
+	if t.Before(c.deadline) {
+		return nil
+	}
+
+	if err := c.ws.SetReadDeadline(t); err != nil {
+		return err
+	}
+
+	return c.SetWriteDeadline(t)
+}
+
+// ping pings the client until the connection is closed.
+func (c *wsConn) ping() {
+	ticker := time.NewTicker(c.opts.PingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+			err := c.ws.WriteControl(websocket.PingMessage, nil, time.Now().Add(c.opts.PongTimeout))
+			if err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,229 @@
+package server
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// startWSServer serves websockets with opts, handing each connection to
+// handle, and returns the websocket URL.
+func startWSServer(t *testing.T, opts WebsocketOptions, handle func(*wsConn)) string {
+	upgrader := newUpgrader(opts)
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ws, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+
+		c := newWSConn(ws, opts)
+		defer c.Close()
+
+		handle(c)
+	}))
+	t.Cleanup(s.Close)
+
+	return "ws" + strings.TrimPrefix(s.URL, "http")
+}
+
+func TestWSConnBinaryFraming(t *testing.T) {
+	got := make(chan []byte, 1)
+	url := startWSServer(t, WebsocketOptions{}, func(c *wsConn) {
+		// messages are read as a stream, across message boundaries
+		b := make([]byte, 6)
+		_, err := io.ReadFull(c, b)
+		if err != nil {
+			return
+		}
+		got <- b
+
+		_, _ = c.Write([]byte("reply"))
+	})
+
+	ws, _, err := websocket.DefaultDialer.Dial(url, nil)
+	require.NoError(t, err)
+	defer ws.Close()
+
+	require.NoError(t, ws.WriteMessage(websocket.BinaryMessage, []byte("abc")))
+	require.NoError(t, ws.WriteMessage(websocket.BinaryMessage, []byte("def")))
+	assert.Equal(t, []byte("abcdef"), <-got)
+
+	typ, b, err := ws.ReadMessage()
+	require.NoError(t, err)
+	assert.Equal(t, websocket.BinaryMessage, typ)
+	assert.Equal(t, []byte("reply"), b)
+}
+
+func TestWebsocketHandshake(t *testing.T) {
+	opts := WebsocketOptions{
+		Compression:    true,
+		AllowedOrigins: []string{"https://example.com"},
+	}
+	url := startWSServer(t, opts, func(c *wsConn) {
+		_, _ = c.Read(make([]byte, 1))
+	})
+
+	dial := func(origin string, protocols []string) (*http.Response, error) {
+		dialer := &websocket.Dialer{
+			Subprotocols:      protocols,
+			EnableCompression: true,
+		}
+
+		header := http.Header{}
+		if origin != "" {
+			header.Set("Origin", origin)
+		}
+
+		ws, resp, err := dialer.Dial(url, header)
+		if err == nil {
+			ws.Close()
+		}
+
+		return resp, err
+	}
+
+	resp, err := dial("https://example.com", []string{"other", WebsocketSubprotocol})
+	require.NoError(t, err)
+	assert.Equal(t, WebsocketSubprotocol, resp.Header.Get("Sec-WebSocket-Protocol"))
+	assert.Contains(t, resp.Header.Get("Sec-WebSocket-Extensions"), "permessage-deflate")
+
+	// clients that do not offer the subprotocol or send an origin still work
+	resp, err = dial("", nil)
+	require.NoError(t, err)
+	assert.Empty(t, resp.Header.Get("Sec-WebSocket-Protocol"))
+
+	resp, err = dial("https://evil.example", nil)
+	require.Error(t, err)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+}
+
+func TestCheckOrigin(t *testing.T) {
+	request := func(origin string) *http.Request {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("Origin", origin)
+		return r
+	}
+
+	assert.True(t, checkOrigin(nil)(request("https://anything.example")))
+	assert.True(t, checkOrigin([]string{"*"})(request("https://anything.example")))
+	assert.True(t, checkOrigin([]string{"https://example.com:8443"})(request("https://EXAMPLE.com:8443")))
+	assert.False(t, checkOrigin([]string{"https://example.com"})(request("http://example.com")))
+}
+
+func TestWSConnKeepalive(t *testing.T) {
+	for _, test := range []struct {
+		name         string
+		pingInterval time.Duration
+		kept         bool
+	}{
+		{"pings keep the connection open", 20 * time.Millisecond, true},
+		{"without pings the deadline applies", 0, false},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			result := make(chan error, 1)
+			url := startWSServer(t, WebsocketOptions{PingInterval: test.pingInterval, PongTimeout: time.Second}, func(c *wsConn) {
+				b := make([]byte, 1)
+				if _, err := c.Read(b); err != nil {
+					result <- err
+					return
+				}
+
+				_ = c.SetDeadline(time.Now().Add(50 * time.Millisecond))
+				_, err := c.Read(b)
+				result <- err
+			})
+
+			ws, _, err := websocket.DefaultDialer.Dial(url, nil)
+			require.NoError(t, err)
+			defer ws.Close()
+
+			// the client answers pings while reading
+			go func() {
+				for {
+					if _, _, err := ws.ReadMessage(); err != nil {
+						return
+					}
+				}
+			}()
+
+			require.NoError(t, ws.WriteMessage(websocket.BinaryMessage, []byte("a")))
+			time.Sleep(200 * time.Millisecond)
+			_ = ws.WriteMessage(websocket.BinaryMessage, []byte("b"))
+
+			err = <-result
+			if test.kept {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestWSConnKeepaliveWrites(t *testing.T) {
+	result := make(chan error, 1)
+	url := startWSServer(t, WebsocketOptions{PingInterval: 20 * time.Millisecond, PongTimeout: time.Second}, func(c *wsConn) {
+		b := make([]byte, 1)
+		if _, err := c.Read(b); err != nil {
+			result <- err
+			return
+		}
+
+		_ = c.SetDeadline(time.Now().Add(50 * time.Millisecond))
+
+		// pongs are handled while reading
+		go c.Read(b)
+
+		time.Sleep(200 * time.Millisecond)
+		_, err := c.Write([]byte("b"))
+		result <- err
+	})
+
+	ws, _, err := websocket.DefaultDialer.Dial(url, nil)
+	require.NoError(t, err)
+	defer ws.Close()
+
+	received := make(chan []byte, 1)
+	go func() {
+		for {
+			_, b, err := ws.ReadMessage()
+			if err != nil {
+				return
+			}
+			received <- b
+		}
+	}()
+
+	require.NoError(t, ws.WriteMessage(websocket.BinaryMessage, []byte("a")))
+
+	// the server can still write after idling past its deadline
+	require.NoError(t, <-result)
+	select {
+	case b := <-received:
+		assert.Equal(t, []byte("b"), b)
+	case <-time.After(time.Second):
+		t.Fatal("message not received")
+	}
+}
+
+func TestWSConnCloseIsEOF(t *testing.T) {
+	result := make(chan error, 1)
+	url := startWSServer(t, WebsocketOptions{}, func(c *wsConn) {
+		_, err := c.Read(make([]byte, 1))
+		result <- err
+	})
+
+	ws, _, err := websocket.DefaultDialer.Dial(url, nil)
+	require.NoError(t, err)
+	ws.Close()
+
+	assert.Equal(t, io.EOF, <-result)
+}